@@ -0,0 +1,274 @@
+package btcpay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is an exact decimal number of arbitrary precision, used for money amounts and exchange rates.
+// The zero value is 0. Amounts are immutable, use Cmp to compare them.
+// An Amount is marshaled into a JSON string and can be unmarshaled from a JSON string or number.
+type Amount struct {
+	unscaled *big.Int // nil means zero
+	scale    int      // number of decimal places, never negative
+}
+
+// NewAmount returns unscaled * 10^-scale. For example, NewAmount(123, 2) is 1.23.
+func NewAmount(unscaled int64, scale int) Amount {
+	return newAmount(big.NewInt(unscaled), scale)
+}
+
+func newAmount(unscaled *big.Int, scale int) Amount {
+	if scale < 0 {
+		unscaled = new(big.Int).Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+	return Amount{
+		unscaled: unscaled,
+		scale:    scale,
+	}
+}
+
+// ParseAmount parses a decimal number like "-0.0366", "12" or "1.5E-8".
+func ParseAmount(s string) (Amount, error) {
+	var input = s
+	s = strings.TrimSpace(s)
+
+	var exponent int
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > 1000 || exp < -1000 {
+			return Amount{}, fmt.Errorf("invalid amount: %q", input)
+		}
+		exponent = exp
+		s = s[:i]
+	}
+
+	var digits = s
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+	var intPart, fracPart = digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intPart, fracPart = digits[:i], digits[i+1:]
+	}
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return Amount{}, fmt.Errorf("invalid amount: %q", input)
+	}
+
+	unscaled, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("invalid amount: %q", input)
+	}
+	if strings.HasPrefix(s, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return newAmount(unscaled, len(fracPart)-exponent), nil
+}
+
+// MustParseAmount is like ParseAmount but panics if s can't be parsed. It is intended for constants.
+func MustParseAmount(s string) Amount {
+	amount, err := ParseAmount(s)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// int returns the unscaled value, which must not be modified.
+func (a Amount) int() *big.Int {
+	if a.unscaled == nil {
+		return new(big.Int)
+	}
+	return a.unscaled
+}
+
+// rescaled returns the unscaled value at the given scale, which must not be less than a.scale.
+func (a Amount) rescaled(scale int) *big.Int {
+	return new(big.Int).Mul(a.int(), pow10(scale-a.scale))
+}
+
+// divRound returns num / den, rounded half away from zero.
+func divRound(num, den *big.Int) *big.Int {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+	var twiceRem = new(big.Int).Abs(rem)
+	twiceRem.Lsh(twiceRem, 1)
+	if twiceRem.Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign() == den.Sign() {
+			quo.Add(quo, big.NewInt(1))
+		} else {
+			quo.Sub(quo, big.NewInt(1))
+		}
+	}
+	return quo
+}
+
+func maxScale(a, b Amount) int {
+	if a.scale > b.scale {
+		return a.scale
+	}
+	return b.scale
+}
+
+func (a Amount) Add(b Amount) Amount {
+	var scale = maxScale(a, b)
+	return newAmount(new(big.Int).Add(a.rescaled(scale), b.rescaled(scale)), scale)
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	var scale = maxScale(a, b)
+	return newAmount(new(big.Int).Sub(a.rescaled(scale), b.rescaled(scale)), scale)
+}
+
+func (a Amount) Mul(b Amount) Amount {
+	return newAmount(new(big.Int).Mul(a.int(), b.int()), a.scale+b.scale)
+}
+
+// Quo returns a / b, rounded half away from zero to the given number of decimal places. It panics if b is zero.
+func (a Amount) Quo(b Amount, places int) Amount {
+	if b.IsZero() {
+		panic("btcpay: division by zero amount")
+	}
+	// a/b = (a.unscaled * 10^(places+b.scale)) / (b.unscaled * 10^a.scale) * 10^-places
+	var num = new(big.Int).Mul(a.int(), pow10(places+b.scale))
+	var den = new(big.Int).Mul(b.int(), pow10(a.scale))
+	return newAmount(divRound(num, den), places)
+}
+
+// Round rounds a half away from zero to the given number of decimal places.
+func (a Amount) Round(places int) Amount {
+	if places < 0 {
+		places = 0
+	}
+	if a.scale <= places {
+		return a
+	}
+	return newAmount(divRound(a.int(), pow10(a.scale-places)), places)
+}
+
+func (a Amount) Neg() Amount {
+	return newAmount(new(big.Int).Neg(a.int()), a.scale)
+}
+
+func (a Amount) Abs() Amount {
+	return newAmount(new(big.Int).Abs(a.int()), a.scale)
+}
+
+// Cmp returns -1 if a < b, 0 if a == b and +1 if a > b.
+func (a Amount) Cmp(b Amount) int {
+	var scale = maxScale(a, b)
+	return a.rescaled(scale).Cmp(b.rescaled(scale))
+}
+
+// Sign returns -1 if a < 0, 0 if a == 0 and +1 if a > 0.
+func (a Amount) Sign() int {
+	return a.int().Sign()
+}
+
+func (a Amount) IsZero() bool {
+	return a.Sign() == 0
+}
+
+// Float64 returns the nearest float64 value. Use it for display or statistics only.
+func (a Amount) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(a.int(), pow10(a.scale)).Float64()
+	return f
+}
+
+// String returns the exact decimal representation, keeping trailing zeros. Example: "0.036652750000".
+func (a Amount) String() string {
+	var digits = new(big.Int).Abs(a.int()).String()
+	if len(digits) <= a.scale {
+		digits = strings.Repeat("0", a.scale-len(digits)+1) + digits
+	}
+	var sign string
+	if a.Sign() < 0 {
+		sign = "-"
+	}
+	if a.scale == 0 {
+		return sign + digits
+	}
+	var point = len(digits) - a.scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// StringFixed rounds a to the given number of decimal places and returns it with exactly that many decimal places. Example: "1.50".
+func (a Amount) StringFixed(places int) string {
+	if places < 0 {
+		places = 0
+	}
+	var rounded = a.Round(places)
+	return newAmount(rounded.rescaled(places), places).String()
+}
+
+// Format returns the amount rounded to the usual number of decimal places of the currency, followed by the currency code. Example: "1.50 EUR".
+func (a Amount) Format(currency string) string {
+	return fmt.Sprintf("%s %s", a.StringFixed(CurrencyDecimals(currency)), currency)
+}
+
+// CurrencyDecimals returns the usual number of decimal places of an ISO 4217 currency or a cryptocurrency. The default is 2.
+func CurrencyDecimals(currency string) int {
+	switch strings.ToUpper(currency) {
+	case "BIF", "CLP", "DJF", "GNF", "ISK", "JPY", "KMF", "KRW", "PYG", "RWF", "SATS", "UGX", "UYI", "VND", "VUV", "XAF", "XOF", "XPF":
+		return 0
+	case "BHD", "IQD", "JOD", "KWD", "LYD", "OMR", "TND":
+		return 3
+	case "BTC", "LTC", "DOGE", "DASH":
+		return 8
+	case "XMR":
+		return 12
+	case "ETH":
+		return 18
+	default:
+		return 2
+	}
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts a JSON string or number. An empty string or null is treated as zero.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*a = Amount{}
+		return nil
+	}
+	var s = string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if strings.TrimSpace(s) == "" {
+			*a = Amount{}
+			return nil
+		}
+	}
+	amount, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
@@ -0,0 +1,100 @@
+package btcpay
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"0", "0"},
+		{"1.23", "1.23"},
+		{"-0.5", "-0.5"},
+		{"+7", "7"},
+		{".5", "0.5"},
+		{"0.036652750000", "0.036652750000"},
+		{"1.5E-8", "0.000000015"},
+		{"12e2", "1200"},
+		{"123456789012345678901234567890.123456789", "123456789012345678901234567890.123456789"},
+	}
+	for _, test := range tests {
+		got, err := ParseAmount(test.input)
+		if err != nil {
+			t.Errorf("%s: %v", test.input, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("%s: got %s, want %s", test.input, got, test.want)
+		}
+	}
+
+	for _, input := range []string{"", "-", ".", "1.2.3", "abc", "1e", "1/3", "0x10"} {
+		if _, err := ParseAmount(input); err == nil {
+			t.Errorf("%q: got nil error", input)
+		}
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+
+	a := MustParseAmount("0.1")
+	b := MustParseAmount("0.2")
+	if got := a.Add(b); got.Cmp(MustParseAmount("0.3")) != 0 {
+		t.Errorf("got %s, want 0.3", got)
+	}
+	if got := a.Sub(b); got.String() != "-0.1" {
+		t.Errorf("got %s, want -0.1", got)
+	}
+	if got := a.Mul(b); got.String() != "0.02" {
+		t.Errorf("got %s, want 0.02", got)
+	}
+	if got := NewAmount(10, 0).Quo(NewAmount(3, 0), 4); got.String() != "3.3333" {
+		t.Errorf("got %s, want 3.3333", got)
+	}
+	if got := NewAmount(-2, 0).Quo(NewAmount(3, 0), 2); got.String() != "-0.67" {
+		t.Errorf("got %s, want -0.67", got)
+	}
+	if got := MustParseAmount("2.345").Round(2); got.String() != "2.35" {
+		t.Errorf("got %s, want 2.35", got)
+	}
+	if got := MustParseAmount("-2.345").StringFixed(2); got != "-2.35" {
+		t.Errorf("got %s, want -2.35", got)
+	}
+	if got := MustParseAmount("1.5").Format("EUR"); got != "1.50 EUR" {
+		t.Errorf("got %s, want 1.50 EUR", got)
+	}
+	if got := MustParseAmount("1234.5").Format("JPY"); got != "1235 JPY" {
+		t.Errorf("got %s, want 1235 JPY", got)
+	}
+	if !(Amount{}).IsZero() || (Amount{}).String() != "0" {
+		t.Error("zero value is not zero")
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+
+	var got struct {
+		A Amount `json:"a"`
+		B Amount `json:"b"`
+		C Amount `json:"c"`
+		D Amount `json:"d"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": "0.00000001", "b": 500000, "c": null, "d": ""}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.A.String() != "0.00000001" || got.B.String() != "500000" || !got.C.IsZero() || !got.D.IsZero() {
+		t.Errorf("got %s %s %s %s", got.A, got.B, got.C, got.D)
+	}
+
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":"0.00000001","b":"500000","c":"0","d":"0"}` {
+		t.Errorf("got %s", data)
+	}
+}
@@ -0,0 +1,107 @@
+package btcpay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrBadRequest      = errors.New("bad request")
+	ErrNotFound        = errors.New("not found")
+	ErrValidation      = errors.New("validation failed")
+)
+
+// maxErrorBodySize limits how much of an error response body is read.
+const maxErrorBodySize = 64 * 1024
+
+// APIError is returned if the BTCPay Server API responds with an unexpected status code.
+// Depending on the status code, it matches ErrUnauthenticated, ErrUnauthorized, ErrBadRequest, ErrNotFound or ErrValidation, so you can use errors.Is.
+type APIError struct {
+	StatusCode  int
+	Code        string       // Greenfield error code, example: "invoice-not-found", empty on validation errors
+	Message     string       // human-readable error message
+	FieldErrors []FieldError // validation errors, usually returned with status 422 or 400
+}
+
+// A FieldError describes why a request field failed validation.
+type FieldError struct {
+	Path    string `json:"path"` // JSON path of the field, example: "amount"
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// newAPIError reads the Greenfield error body from resp. Greenfield returns either an object with code and message or an array of field errors.
+func newAPIError(resp *http.Response) *APIError {
+	var apiErr = &APIError{
+		StatusCode: resp.StatusCode,
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return apiErr
+	}
+	body = []byte(strings.TrimSpace(string(body)))
+
+	switch {
+	case len(body) > 0 && body[0] == '[':
+		_ = json.Unmarshal(body, &apiErr.FieldErrors)
+	case len(body) > 0 && body[0] == '{':
+		var errBody struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &errBody) == nil {
+			apiErr.Code = errBody.Code
+			apiErr.Message = errBody.Message
+		}
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	var msg = fmt.Sprintf("response status: %d", e.StatusCode)
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Code)
+	}
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	if len(e.FieldErrors) > 0 {
+		var fieldErrs = make([]string, len(e.FieldErrors))
+		for i, fieldErr := range e.FieldErrors {
+			fieldErrs[i] = fieldErr.String()
+		}
+		msg = fmt.Sprintf("%s: %s", msg, strings.Join(fieldErrs, ", "))
+	}
+	return msg
+}
+
+// Is maps the status code to the corresponding sentinel error.
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized: // 401, "Unauthorized" should be "Unauthenticated"
+		return target == ErrUnauthenticated
+	case http.StatusForbidden:
+		return target == ErrUnauthorized
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusUnprocessableEntity:
+		return target == ErrValidation
+	default:
+		return false
+	}
+}
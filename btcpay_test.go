@@ -19,7 +19,7 @@ func TestInvoice(t *testing.T) {
 	}
 
 	ir := &InvoiceRequest{
-		Amount:   1.23,
+		Amount:   MustParseAmount("1.23"),
 		Currency: "EUR",
 	}
 	ir.OrderID = "Test"
@@ -34,7 +34,7 @@ func TestInvoice(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if got.Amount != 1.23 || got.Currency != "EUR" {
+	if got.Amount.Cmp(MustParseAmount("1.23")) != 0 || got.Currency != "EUR" {
 		t.Fail()
 	}
 
@@ -63,11 +63,16 @@ func TestInvoice(t *testing.T) {
 	mac.Write(body)
 	webhookRequest.Header.Add("BTCPay-Sig", fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil))))
 
-	event, err := store.ProcessWebhook(webhookRequest)
+	processed, err := store.ProcessWebhook(webhookRequest)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	event, ok := processed.(*InvoiceCreatedEvent)
+	if !ok {
+		t.Fatalf("got %T", processed)
+	}
+
 	if event.StoreID != store.ID || event.Type != EventInvoiceCreated || event.InvoiceID != got.ID {
 		t.Fail()
 	}
@@ -85,7 +90,7 @@ func TestPaymentRequest(t *testing.T) {
 	}
 
 	request := &PaymentRequestRequest{
-		Amount:   1.23,
+		Amount:   MustParseAmount("1.23"),
 		Currency: "EUR",
 		Title:    "Test payment request",
 	}
@@ -101,7 +106,7 @@ func TestPaymentRequest(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if got.Amount != 1.23 || got.Currency != "EUR" {
+	if got.Amount.Cmp(MustParseAmount("1.23")) != 0 || got.Currency != "EUR" {
 		t.Fail()
 	}
 }
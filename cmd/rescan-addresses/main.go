@@ -19,7 +19,7 @@ func main() {
 	for {
 		fmt.Print(".")
 		_, err := store.CreateInvoice(&btcpay.InvoiceRequest{
-			Amount:   0,
+			Amount:   btcpay.NewAmount(0, 0),
 			Currency: "EUR",
 			InvoiceCheckout: btcpay.InvoiceCheckout{
 				ExpirationMinutes: 0,
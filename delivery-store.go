@@ -0,0 +1,161 @@
+package btcpay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A DeliveryStore records which webhook deliveries have been processed, so WebhookHandler processes each delivery at most once.
+// Implementations must be safe for concurrent use.
+type DeliveryStore interface {
+	// Claim records the key and returns true if it has not been claimed before, or false if it is a duplicate.
+	Claim(key string) (bool, error)
+	// Release removes a claim, so the delivery can be processed again. It is called if processing has failed.
+	Release(key string) error
+}
+
+// DeliveryKey returns the key which identifies a delivery across redeliveries: OriginalDeliveryID if present, else DeliveryID.
+func DeliveryKey(event Event) string {
+	var envelope = event.Envelope()
+	if envelope.OriginalDeliveryID != "" {
+		return envelope.OriginalDeliveryID
+	}
+	return envelope.DeliveryID
+}
+
+// MemoryDeliveryStore is a DeliveryStore which keeps the keys in memory. They are lost when the program exits.
+type MemoryDeliveryStore struct {
+	mutex sync.Mutex
+	keys  map[string]struct{}
+}
+
+func NewMemoryDeliveryStore() *MemoryDeliveryStore {
+	return &MemoryDeliveryStore{
+		keys: make(map[string]struct{}),
+	}
+}
+
+func (m *MemoryDeliveryStore) Claim(key string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.keys == nil {
+		m.keys = make(map[string]struct{})
+	}
+	if _, ok := m.keys[key]; ok {
+		return false, nil
+	}
+	m.keys[key] = struct{}{}
+	return true, nil
+}
+
+func (m *MemoryDeliveryStore) Release(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.keys, key)
+	return nil
+}
+
+// deliveryRecord is a line in the file of a FileDeliveryStore.
+type deliveryRecord struct {
+	Key      string   `json:"key"`
+	Time     UnixTime `json:"time"`
+	Released bool     `json:"released,omitempty"`
+}
+
+// FileDeliveryStore is a DeliveryStore which appends claims and releases as JSON lines to a file, so they survive restarts.
+// The file grows with every delivery. It can be truncated when older deliveries are not redelivered any more.
+type FileDeliveryStore struct {
+	mutex  sync.Mutex
+	file   *os.File
+	memory *MemoryDeliveryStore
+}
+
+// OpenFileDeliveryStore reads the claims from the given file and opens it for appending. The file is created with chmod 600 if it doesn't exist.
+// An unterminated last line, which is left if the program crashes while writing it, is removed.
+func OpenFileDeliveryStore(path string) (*FileDeliveryStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	// remove an unterminated last line
+	if complete := bytes.LastIndexByte(data, '\n') + 1; complete < len(data) {
+		if err := file.Truncate(int64(complete)); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("truncating %s: %w", path, err)
+		}
+		data = data[:complete]
+	}
+
+	var memory = NewMemoryDeliveryStore()
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var record deliveryRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("reading %s line %d: %w", path, i+1, err)
+		}
+		if record.Released {
+			memory.Release(record.Key)
+		} else {
+			memory.Claim(record.Key)
+		}
+	}
+	return &FileDeliveryStore{
+		file:   file,
+		memory: memory,
+	}, nil
+}
+
+func (f *FileDeliveryStore) Claim(key string) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if claimed, _ := f.memory.Claim(key); !claimed {
+		return false, nil
+	}
+	if err := f.write(deliveryRecord{Key: key, Time: NewUnixTime(time.Now())}); err != nil {
+		f.memory.Release(key)
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *FileDeliveryStore) Release(key string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.write(deliveryRecord{Key: key, Time: NewUnixTime(time.Now()), Released: true}); err != nil {
+		return err
+	}
+	return f.memory.Release(key)
+}
+
+// Close closes the file.
+func (f *FileDeliveryStore) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
+
+// write appends a record to the file and syncs it, so a claim is not lost if the program crashes afterwards.
+func (f *FileDeliveryStore) write(record deliveryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := f.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing delivery record: %w", err)
+	}
+	return f.file.Sync()
+}
@@ -0,0 +1,94 @@
+package btcpay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testDeliveryStore(t *testing.T, store DeliveryStore) {
+	if claimed, err := store.Claim("delivery-1"); err != nil || !claimed {
+		t.Errorf("first claim: got %t, %v", claimed, err)
+	}
+	if claimed, err := store.Claim("delivery-1"); err != nil || claimed {
+		t.Errorf("second claim: got %t, %v", claimed, err)
+	}
+	if claimed, err := store.Claim("delivery-2"); err != nil || !claimed {
+		t.Errorf("other claim: got %t, %v", claimed, err)
+	}
+	if err := store.Release("delivery-2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryDeliveryStore(t *testing.T) {
+	testDeliveryStore(t, NewMemoryDeliveryStore())
+}
+
+func TestFileDeliveryStore(t *testing.T) {
+
+	var path = filepath.Join(t.TempDir(), "deliveries.jsonl")
+
+	store, err := OpenFileDeliveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDeliveryStore(t, store)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = OpenFileDeliveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if claimed, _ := store.Claim("delivery-1"); claimed {
+		t.Errorf("claim has not been persisted")
+	}
+	if claimed, _ := store.Claim("delivery-2"); !claimed {
+		t.Errorf("release has not been persisted")
+	}
+}
+
+func TestDeliveryKey(t *testing.T) {
+	var event = &InvoiceSettledEvent{}
+	event.DeliveryID = "delivery-2"
+	if key := DeliveryKey(event); key != "delivery-2" {
+		t.Errorf("got %s", key)
+	}
+	event.OriginalDeliveryID = "delivery-1"
+	if key := DeliveryKey(event); key != "delivery-1" {
+		t.Errorf("got %s", key)
+	}
+}
+
+func TestFileDeliveryStoreUnterminatedLine(t *testing.T) {
+
+	var path = filepath.Join(t.TempDir(), "deliveries.jsonl")
+	if err := os.WriteFile(path, []byte(`{"key":"delivery-1","time":1610000000}`+"\n"+`{"key":"deliv`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenFileDeliveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed, _ := store.Claim("delivery-1"); claimed {
+		t.Errorf("claim has not been read")
+	}
+	if claimed, err := store.Claim("delivery-2"); err != nil || !claimed {
+		t.Errorf("got %t, %v", claimed, err)
+	}
+	store.Close()
+
+	// the new claim must be on its own line
+	store, err = OpenFileDeliveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if claimed, _ := store.Claim("delivery-2"); claimed {
+		t.Errorf("claim has not been persisted")
+	}
+}
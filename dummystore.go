@@ -1,44 +1,138 @@
 package btcpay
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"time"
 )
 
-// DummyStore is designed for testing only. It supports invoices only and is not thread-safe.
+// DummyStore is designed for testing only. It supports invoices and payment requests only and is not thread-safe.
 type DummyStore struct {
-	Invoices map[string]*Invoice
+	Invoices        map[string]*Invoice
+	PaymentRequests map[string]*PaymentRequest
+	Rates           map[string]Amount // exchange rates of the synthetic payment methods, key: crypto code, regardless of the invoice currency
+	activated       map[string]bool   // key: invoice ID and payment method
 }
 
 func NewDummyStore() *DummyStore {
 	return &DummyStore{
-		Invoices: make(map[string]*Invoice),
+		Invoices:        make(map[string]*Invoice),
+		PaymentRequests: make(map[string]*PaymentRequest),
+		Rates: map[string]Amount{
+			"BTC": NewAmount(50000, 0),
+		},
+		activated: make(map[string]bool),
 	}
 }
 
+func (s *DummyStore) ActivateInvoicePaymentMethod(invoiceID string, paymentMethod string) error {
+	invoice, err := s.GetInvoice(invoiceID)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.Rates[paymentMethod]; !ok {
+		return ErrNotFound
+	}
+	s.activated[invoice.ID+"/"+paymentMethod] = true
+	return nil
+}
+
+func (s *DummyStore) ActivateInvoicePaymentMethodContext(_ context.Context, invoiceID string, paymentMethod string) error {
+	return s.ActivateInvoicePaymentMethod(invoiceID, paymentMethod)
+}
+
+func (s *DummyStore) ArchiveInvoice(id string) error {
+	invoice, err := s.GetInvoice(id)
+	if err != nil {
+		return err
+	}
+	invoice.Archived = true
+	return nil
+}
+
+func (s *DummyStore) ArchiveInvoiceContext(_ context.Context, id string) error {
+	return s.ArchiveInvoice(id)
+}
+
+func (s *DummyStore) ArchivePaymentRequest(id string) error {
+	paymentRequest, err := s.GetPaymentRequest(id)
+	if err != nil {
+		return err
+	}
+	paymentRequest.Archived = true
+	return nil
+}
+
+func (s *DummyStore) ArchivePaymentRequestContext(_ context.Context, id string) error {
+	return s.ArchivePaymentRequest(id)
+}
+
 func (*DummyStore) CheckInvoiceAuth() error {
 	return nil
 }
 
+func (s *DummyStore) CheckInvoiceAuthContext(_ context.Context) error {
+	return s.CheckInvoiceAuth()
+}
+
 func (s *DummyStore) CreateInvoice(req *InvoiceRequest) (*Invoice, error) {
 	id := fmt.Sprintf("dummy-invoice-%d", time.Now().UnixNano())
+	expirationMinutes := req.ExpirationMinutes
+	if expirationMinutes == 0 {
+		expirationMinutes = 15 // BTCPay Server default
+	}
 	invoice := &Invoice{
 		InvoiceRequest:       *req,
 		ID:                   id,
 		CheckoutLink:         "http://example.com",
-		CreatedTime:          time.Now().Unix(),
-		ExpirationTime:       time.Now().Unix() + int64(60*req.ExpirationMinutes),
-		MonitoringExpiration: time.Now().Unix() + int64(60*req.MonitoringMinutes),
+		CreatedTime:          NewUnixTime(time.Now()),
+		ExpirationTime:       NewUnixTime(time.Now().Add(time.Duration(expirationMinutes) * time.Minute)),
+		MonitoringExpiration: NewUnixTime(time.Now().Add(time.Duration(req.MonitoringMinutes) * time.Minute)),
 		Status:               InvoiceNew,
 	}
 	s.Invoices[id] = invoice
 	return invoice, nil
 }
 
-func (*DummyStore) CreatePaymentRequest(req *PaymentRequestRequest) (*PaymentRequest, error) {
-	return nil, errors.New("not implemented")
+func (s *DummyStore) CreateInvoiceContext(_ context.Context, req *InvoiceRequest) (*Invoice, error) {
+	return s.CreateInvoice(req)
+}
+
+func (s *DummyStore) CreateInvoiceOnce(req *InvoiceRequest) (*Invoice, error) {
+	if req == nil || req.InvoiceMetadata.OrderID == "" {
+		return nil, ErrOrderIDMissing
+	}
+	var invoices = make([]Invoice, 0, len(s.Invoices))
+	for _, invoice := range s.Invoices {
+		invoices = append(invoices, *invoice)
+	}
+	if invoice := findReusableInvoice(invoices, req.InvoiceMetadata.OrderID, time.Now()); invoice != nil {
+		return s.Invoices[invoice.ID], nil
+	}
+	return s.CreateInvoice(req)
+}
+
+func (s *DummyStore) CreateInvoiceOnceContext(_ context.Context, req *InvoiceRequest) (*Invoice, error) {
+	return s.CreateInvoiceOnce(req)
+}
+
+func (s *DummyStore) CreatePaymentRequest(req *PaymentRequestRequest) (*PaymentRequest, error) {
+	id := fmt.Sprintf("dummy-payment-request-%d", time.Now().UnixNano())
+	paymentRequest := &PaymentRequest{
+		PaymentRequestRequest: *req,
+		Created:               NewRFC3339Time(time.Now()),
+		ID:                    id,
+		Status:                PaymentRequestPending,
+	}
+	s.PaymentRequests[id] = paymentRequest
+	return paymentRequest, nil
+}
+
+func (s *DummyStore) CreatePaymentRequestContext(_ context.Context, req *PaymentRequestRequest) (*PaymentRequest, error) {
+	return s.CreatePaymentRequest(req)
 }
 
 func (s *DummyStore) GetInvoice(id string) (*Invoice, error) {
@@ -46,12 +140,96 @@ func (s *DummyStore) GetInvoice(id string) (*Invoice, error) {
 	if ok {
 		return invoice, nil
 	} else {
-		return nil, errors.New("not found")
+		return nil, ErrNotFound
 	}
 }
 
-func (*DummyStore) GetPaymentRequest(id string) (*PaymentRequest, error) {
-	return nil, errors.New("not implemented")
+func (s *DummyStore) GetInvoiceContext(_ context.Context, id string) (*Invoice, error) {
+	return s.GetInvoice(id)
+}
+
+// GetInvoicePaymentMethods returns a synthetic payment method for each of the invoice payment methods (default: all Rates).
+// If the invoice status is InvoiceProcessing or InvoiceSettled, each payment method contains a payment of the full amount.
+// If the invoice has LazyPaymentMethods set, payment methods have no destination until they are activated.
+func (s *DummyStore) GetInvoicePaymentMethods(id string) ([]InvoicePaymentMethod, error) {
+	invoice, err := s.GetInvoice(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var cryptoCodes = invoice.PaymentMethods
+	if len(cryptoCodes) == 0 {
+		for cryptoCode := range s.Rates {
+			cryptoCodes = append(cryptoCodes, cryptoCode)
+		}
+		sort.Strings(cryptoCodes)
+	}
+
+	var methods = []InvoicePaymentMethod{}
+	for _, cryptoCode := range cryptoCodes {
+		rate, ok := s.Rates[cryptoCode]
+		if !ok || rate.Sign() <= 0 {
+			continue
+		}
+		method := InvoicePaymentMethod{
+			PaymentMethod: cryptoCode,
+			CryptoCode:    cryptoCode,
+			Destination:   fmt.Sprintf("dummy-destination-%s-%s", cryptoCode, invoice.ID),
+			Rate:          rate,
+			Amount:        invoice.Amount.Quo(rate, CurrencyDecimals(cryptoCode)),
+			Activated:     true,
+		}
+		if invoice.LazyPaymentMethods != nil && *invoice.LazyPaymentMethods && !s.activated[invoice.ID+"/"+cryptoCode] {
+			method.Destination = ""
+			method.Activated = false
+		}
+		method.PaymentLink = method.Destination
+		method.Due = method.Amount
+		if invoice.Status.IsPaid() {
+			payment := InvoicePayment{
+				ID:           fmt.Sprintf("dummy-payment-%s-%s", cryptoCode, invoice.ID),
+				ReceivedDate: invoice.CreatedTime,
+				Value:        method.Amount,
+				Status:       PaymentProcessing,
+				Destination:  method.Destination,
+			}
+			if invoice.Status == InvoiceSettled {
+				payment.Status = PaymentSettled
+			}
+			method.Payments = []InvoicePayment{payment}
+			method.PaymentMethodPaid = method.Amount
+			method.TotalPaid = method.Amount
+			method.Due = Amount{}
+		}
+		methods = append(methods, method)
+	}
+	return methods, nil
+}
+
+func (s *DummyStore) GetInvoicePaymentMethodsContext(_ context.Context, id string) ([]InvoicePaymentMethod, error) {
+	return s.GetInvoicePaymentMethods(id)
+}
+
+// GetInvoicePaymentMethodsIncludeUnaccounted returns the same as GetInvoicePaymentMethods, as synthetic payments always count towards the invoice.
+func (s *DummyStore) GetInvoicePaymentMethodsIncludeUnaccounted(id string) ([]InvoicePaymentMethod, error) {
+	return s.GetInvoicePaymentMethods(id)
+}
+
+func (s *DummyStore) GetInvoicePaymentMethodsIncludeUnaccountedContext(_ context.Context, id string) ([]InvoicePaymentMethod, error) {
+	return s.GetInvoicePaymentMethods(id)
+}
+
+func (s *DummyStore) GetPaymentRequest(id string) (*PaymentRequest, error) {
+	paymentRequest, ok := s.PaymentRequests[id]
+	if ok {
+		return paymentRequest, nil
+	} else {
+		return nil, ErrNotFound
+	}
+}
+
+func (s *DummyStore) GetPaymentRequestContext(_ context.Context, id string) (*PaymentRequest, error) {
+	return s.GetPaymentRequest(id)
 }
 
 func (*DummyStore) GetServerStatus() (*ServerStatus, error) {
@@ -69,6 +247,10 @@ func (*DummyStore) GetServerStatus() (*ServerStatus, error) {
 	}, nil
 }
 
+func (s *DummyStore) GetServerStatusContext(_ context.Context) (*ServerStatus, error) {
+	return s.GetServerStatus()
+}
+
 func (*DummyStore) InvoiceCheckoutLink(id string) string {
 	return id
 }
@@ -77,6 +259,64 @@ func (*DummyStore) InvoiceCheckoutLinkPreferOnion(id string) string {
 	return id
 }
 
+func (s *DummyStore) ListPaymentRequests(includeArchived bool) ([]PaymentRequest, error) {
+	var paymentRequests = []PaymentRequest{}
+	for _, paymentRequest := range s.PaymentRequests {
+		if paymentRequest.Archived && !includeArchived {
+			continue
+		}
+		paymentRequests = append(paymentRequests, *paymentRequest)
+	}
+	return paymentRequests, nil
+}
+
+func (s *DummyStore) ListPaymentRequestsContext(_ context.Context, includeArchived bool) ([]PaymentRequest, error) {
+	return s.ListPaymentRequests(includeArchived)
+}
+
+func (s *DummyStore) MarkInvoiceStatus(id string, status InvoiceStatus) (*Invoice, error) {
+	if err := checkMarkStatus(status); err != nil {
+		return nil, err
+	}
+	invoice, err := s.GetInvoice(id)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status != status && !invoice.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("invoice status can't change from %s to %s", invoice.Status, status)
+	}
+	invoice.Status = status
+	invoice.AdditionalStatus = InvoiceAdditionalMarked
+	return invoice, nil
+}
+
+func (s *DummyStore) MarkInvoiceStatusContext(_ context.Context, id string, status InvoiceStatus) (*Invoice, error) {
+	return s.MarkInvoiceStatus(id, status)
+}
+
+func (s *DummyStore) PayPaymentRequest(id string, amount Amount) (*Invoice, error) {
+	paymentRequest, err := s.GetPaymentRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := paymentRequest.checkPaymentAmount(amount); err != nil {
+		return nil, err
+	}
+	if amount.IsZero() {
+		amount = paymentRequest.Amount
+	}
+	req := &InvoiceRequest{
+		Amount:   amount,
+		Currency: paymentRequest.Currency,
+	}
+	req.InvoiceMetadata.OrderID = paymentRequest.ID
+	return s.CreateInvoice(req)
+}
+
+func (s *DummyStore) PayPaymentRequestContext(_ context.Context, id string, amount Amount) (*Invoice, error) {
+	return s.PayPaymentRequest(id, amount)
+}
+
 func (*DummyStore) PaymentRequestLink(id string) string {
 	return id
 }
@@ -85,6 +325,40 @@ func (*DummyStore) PaymentRequestLinkPreferOnion(id string) string {
 	return id
 }
 
-func (*DummyStore) ProcessWebhook(r *http.Request) (*InvoiceEvent, error) {
+func (*DummyStore) ProcessWebhook(r *http.Request) (Event, error) {
 	return nil, errors.New("not implemented")
 }
+
+func (*DummyStore) ReceiptLink(id string) string {
+	return id
+}
+
+func (*DummyStore) ReceiptLinkPreferOnion(id string) string {
+	return id
+}
+
+func (s *DummyStore) UnarchiveInvoice(id string) (*Invoice, error) {
+	invoice, err := s.GetInvoice(id)
+	if err != nil {
+		return nil, err
+	}
+	invoice.Archived = false
+	return invoice, nil
+}
+
+func (s *DummyStore) UnarchiveInvoiceContext(_ context.Context, id string) (*Invoice, error) {
+	return s.UnarchiveInvoice(id)
+}
+
+func (s *DummyStore) UpdatePaymentRequest(id string, req *PaymentRequestRequest) (*PaymentRequest, error) {
+	paymentRequest, err := s.GetPaymentRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	paymentRequest.PaymentRequestRequest = *req
+	return paymentRequest, nil
+}
+
+func (s *DummyStore) UpdatePaymentRequestContext(_ context.Context, id string, req *PaymentRequestRequest) (*PaymentRequest, error) {
+	return s.UpdatePaymentRequest(id, req)
+}
@@ -0,0 +1,187 @@
+package btcpay
+
+import (
+	"errors"
+	"testing"
+)
+
+var _ StoreContext = &DummyStore{}
+
+func TestDummyStoreCreateInvoiceOnce(t *testing.T) {
+
+	store := NewDummyStore()
+	req := &InvoiceRequest{Currency: "EUR"}
+	if _, err := store.CreateInvoiceOnce(req); !errors.Is(err, ErrOrderIDMissing) {
+		t.Errorf("got %v, want ErrOrderIDMissing", err)
+	}
+
+	req.InvoiceMetadata.OrderID = "order-1"
+	created, err := store.CreateInvoiceOnce(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reused, err := store.CreateInvoiceOnce(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused.ID != created.ID || len(store.Invoices) != 1 {
+		t.Errorf("got invoice %s and %d invoices, want the existing invoice %s", reused.ID, len(store.Invoices), created.ID)
+	}
+
+	store.Invoices[created.ID].Status = InvoiceExpired
+	if recreated, _ := store.CreateInvoiceOnce(req); recreated.ID == created.ID {
+		t.Error("expired invoice has been reused")
+	}
+}
+
+func TestDummyStoreMarkInvoiceStatus(t *testing.T) {
+
+	store := NewDummyStore()
+	created, err := store.CreateInvoice(&InvoiceRequest{Currency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.MarkInvoiceStatus(created.ID, InvoiceExpired); err == nil {
+		t.Error("marking an invoice as expired should fail")
+	}
+
+	marked, err := store.MarkInvoiceStatus(created.ID, InvoiceSettled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if marked.Status != InvoiceSettled || !marked.ManuallyMarked() {
+		t.Errorf("got status %s and additional status %s", marked.Status, marked.AdditionalStatus)
+	}
+
+	if err := store.ArchiveInvoice(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.GetInvoice(created.ID); !got.Archived {
+		t.Error("invoice has not been archived")
+	}
+	if got, _ := store.UnarchiveInvoice(created.ID); got.Archived {
+		t.Error("invoice has not been unarchived")
+	}
+}
+
+func TestDummyStorePaymentRequests(t *testing.T) {
+
+	store := NewDummyStore()
+	created, err := store.CreatePaymentRequest(&PaymentRequestRequest{
+		Amount:   NewAmount(10, 0),
+		Currency: "EUR",
+		Title:    "Test payment request",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := store.UpdatePaymentRequest(created.ID, &PaymentRequestRequest{
+		Amount:   NewAmount(12, 0),
+		Currency: "EUR",
+		Title:    "Test payment request",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Amount.Cmp(NewAmount(12, 0)) != 0 || updated.Status != PaymentRequestPending {
+		t.Errorf("got amount %v and status %s", updated.Amount, updated.Status)
+	}
+
+	if err := store.ArchivePaymentRequest(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := store.ListPaymentRequests(false); len(list) != 0 {
+		t.Errorf("got %d payment requests, want 0", len(list))
+	}
+	if list, _ := store.ListPaymentRequests(true); len(list) != 1 {
+		t.Errorf("got %d payment requests, want 1", len(list))
+	}
+}
+
+func TestDummyStorePayPaymentRequest(t *testing.T) {
+
+	store := NewDummyStore()
+	paymentRequest, err := store.CreatePaymentRequest(&PaymentRequestRequest{
+		Amount:   NewAmount(10, 0),
+		Currency: "EUR",
+		Title:    "Test payment request",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.PayPaymentRequest(paymentRequest.ID, NewAmount(5, 0)); !errors.Is(err, ErrValidation) {
+		t.Errorf("got %v, want ErrValidation", err)
+	}
+
+	invoice, err := store.PayPaymentRequest(paymentRequest.ID, Amount{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Amount.Cmp(NewAmount(10, 0)) != 0 || invoice.InvoiceMetadata.OrderID != paymentRequest.ID {
+		t.Errorf("got amount %v and order ID %s", invoice.Amount, invoice.InvoiceMetadata.OrderID)
+	}
+}
+
+func TestDummyStorePaymentMethods(t *testing.T) {
+
+	store := NewDummyStore()
+	invoice, err := store.CreateInvoice(&InvoiceRequest{
+		Amount:   NewAmount(100, 0),
+		Currency: "EUR",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods, err := store.GetInvoicePaymentMethods(invoice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 1 || methods[0].CryptoCode != "BTC" || methods[0].Amount.String() != "0.00200000" || len(methods[0].Payments) != 0 {
+		t.Fatalf("got %+v", methods)
+	}
+
+	if _, err := store.MarkInvoiceStatus(invoice.ID, InvoiceSettled); err != nil {
+		t.Fatal(err)
+	}
+	methods, err = store.GetInvoicePaymentMethods(invoice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods[0].Payments) != 1 || methods[0].Payments[0].Status != PaymentSettled || !methods[0].Due.IsZero() {
+		t.Errorf("got %+v", methods[0])
+	}
+}
+
+func TestDummyStoreActivatePaymentMethod(t *testing.T) {
+
+	store := NewDummyStore()
+	lazy := true
+	invoice, err := store.CreateInvoice(&InvoiceRequest{
+		Amount:          NewAmount(100, 0),
+		Currency:        "EUR",
+		InvoiceCheckout: InvoiceCheckout{LazyPaymentMethods: &lazy},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods, _ := store.GetInvoicePaymentMethods(invoice.ID)
+	if methods[0].Activated || methods[0].Destination != "" {
+		t.Errorf("payment method should not be activated: %+v", methods[0])
+	}
+
+	if err := store.ActivateInvoicePaymentMethod(invoice.ID, "XMR"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if err := store.ActivateInvoicePaymentMethod(invoice.ID, "BTC"); err != nil {
+		t.Fatal(err)
+	}
+	methods, _ = store.GetInvoicePaymentMethods(invoice.ID)
+	if !methods[0].Activated || methods[0].Destination == "" {
+		t.Errorf("payment method should be activated: %+v", methods[0])
+	}
+}
@@ -0,0 +1,137 @@
+package btcpay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrRateOutOfRange = errors.New("exchange rate out of range")
+
+// An EventValidator checks a webhook event after its signature has been verified, see ServerStore.Validators.
+// It should wrap ErrInvalidEvent if the event is rejected, and return other errors if the check could not be done, e.g. because an API request failed.
+// WebhookHandler responds with 400 or 500 respectively.
+type EventValidator interface {
+	ValidateEvent(ctx context.Context, store StoreContext, event Event) error
+}
+
+// EventValidatorFunc is an adapter which allows the use of a function as an EventValidator.
+type EventValidatorFunc func(ctx context.Context, store StoreContext, event Event) error
+
+func (f EventValidatorFunc) ValidateEvent(ctx context.Context, store StoreContext, event Event) error {
+	return f(ctx, store, event)
+}
+
+// StoreIDValidator rejects events of other stores. This mitigates BTCPay Server misconfigurations.
+func StoreIDValidator(storeID string) EventValidator {
+	return EventValidatorFunc(func(ctx context.Context, store StoreContext, event Event) error {
+		if got := event.Envelope().StoreID; got != storeID {
+			return fmt.Errorf("%w: event store ID %s does not match selected store ID %s", ErrInvalidEvent, got, storeID)
+		}
+		return nil
+	})
+}
+
+// ForEventTypes returns a validator which applies validator to events of the given types only.
+func ForEventTypes(validator EventValidator, types ...EventType) EventValidator {
+	return EventValidatorFunc(func(ctx context.Context, store StoreContext, event Event) error {
+		for _, t := range types {
+			if event.Envelope().Type == t {
+				return validator.ValidateEvent(ctx, store, event)
+			}
+		}
+		return nil
+	})
+}
+
+// PaymentEventTypes are the invoice event types which are related to payments. The default rate check applies to them only.
+var PaymentEventTypes = []EventType{
+	EventInvoicePaymentSettled,
+	EventInvoiceProcessing,
+	EventInvoiceReceivedPayment,
+	EventInvoiceSettled,
+}
+
+// RateValidator rejects invoice events if an exchange rate of the invoice is out of bounds. Other events are accepted.
+// It requests the invoice payment methods from the store, and the invoice if Source is used.
+type RateValidator struct {
+	MaxRates     map[string]Amount // key: crypto code, example: {"XMR": 1000, "BTC": 500000}
+	MinRates     map[string]Amount // key: crypto code
+	Source       RateSource        // reference rates, can be nil
+	MaxDeviation Amount            // max relative deviation from the Source rate, example: 0.05 for 5 %, zero disables the check
+}
+
+func (v RateValidator) enabled() bool {
+	return len(v.MaxRates) > 0 || len(v.MinRates) > 0 || v.checksDeviation()
+}
+
+func (v RateValidator) checksDeviation() bool {
+	return v.Source != nil && !v.MaxDeviation.IsZero()
+}
+
+func (v RateValidator) ValidateEvent(ctx context.Context, store StoreContext, event Event) error {
+	invoiceEvent, ok := AsInvoiceEvent(event)
+	if !ok {
+		return nil
+	}
+	var currency string
+	if v.checksDeviation() {
+		invoice, err := store.GetInvoiceContext(ctx, invoiceEvent.InvoiceID)
+		if err != nil {
+			return fmt.Errorf("getting invoice: %w", err)
+		}
+		currency = invoice.Currency
+	}
+	err := v.ValidateInvoice(ctx, store, invoiceEvent.InvoiceID, currency)
+	if errors.Is(err, ErrRateOutOfRange) {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	return err
+}
+
+// ValidateInvoice returns an error wrapping ErrRateOutOfRange if an exchange rate of the given invoice is out of bounds.
+// The invoice currency is required if Source is used. Currency pairs which are unavailable in Source are not checked against it.
+func (v RateValidator) ValidateInvoice(ctx context.Context, store StoreContext, invoiceID string, currency string) error {
+	if !v.enabled() {
+		return nil
+	}
+	paymentMethods, err := store.GetInvoicePaymentMethodsContext(ctx, invoiceID)
+	if err != nil {
+		return fmt.Errorf("getting payment methods from invoice: %w", err)
+	}
+	for cryptoCode, maxRate := range v.MaxRates {
+		if err := ValidateRate(paymentMethods, cryptoCode, maxRate); err != nil {
+			return fmt.Errorf("%w: %v", ErrRateOutOfRange, err)
+		}
+	}
+	for cryptoCode, minRate := range v.MinRates {
+		if err := ValidateMinRate(paymentMethods, cryptoCode, minRate); err != nil {
+			return fmt.Errorf("%w: %v", ErrRateOutOfRange, err)
+		}
+	}
+	if !v.checksDeviation() {
+		return nil
+	}
+	var checked = make(map[string]bool)
+	for _, method := range paymentMethods {
+		if checked[method.CryptoCode] {
+			continue
+		}
+		checked[method.CryptoCode] = true
+		reference, err := v.Source.Rate(ctx, method.CryptoCode, currency)
+		switch {
+		case errors.Is(err, ErrRateUnavailable):
+			continue
+		case err != nil:
+			return fmt.Errorf("getting reference rate: %w", err)
+		}
+		err = ValidateRateDeviation(paymentMethods, method.CryptoCode, reference, v.MaxDeviation)
+		switch {
+		case errors.Is(err, ErrRateUnavailable):
+			continue
+		case err != nil:
+			return fmt.Errorf("%w: %v", ErrRateOutOfRange, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package btcpay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRateValidator(t *testing.T) {
+
+	store := NewDummyStore()
+	store.Rates = map[string]Amount{"BTC": MustParseAmount("50000")}
+	invoice, err := store.CreateInvoice(&InvoiceRequest{Amount: MustParseAmount("10"), Currency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event = &InvoiceSettledEvent{}
+	event.Type = EventInvoiceSettled
+	event.InvoiceID = invoice.ID
+
+	var tests = []struct {
+		validator RateValidator
+		valid     bool
+	}{
+		{RateValidator{}, true},
+		{RateValidator{MaxRates: map[string]Amount{"BTC": MustParseAmount("60000")}}, true},
+		{RateValidator{MaxRates: map[string]Amount{"BTC": MustParseAmount("40000")}}, false},
+		{RateValidator{MinRates: map[string]Amount{"BTC": MustParseAmount("40000")}}, true},
+		{RateValidator{MinRates: map[string]Amount{"BTC": MustParseAmount("60000")}}, false},
+		{RateValidator{MinRates: map[string]Amount{"XMR": MustParseAmount("60000")}}, true},
+	}
+
+	for _, test := range tests {
+		err := test.validator.ValidateEvent(context.Background(), store, event)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%+v: got %v", test.validator, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("got %v, want ErrInvalidEvent", err)
+		}
+	}
+
+	// reference rates
+	var source = StaticRateSource{"BTC_EUR": MustParseAmount("52000")}
+	for _, test := range []struct {
+		deviation string
+		valid     bool
+	}{
+		{"0.05", true},
+		{"0.03", false},
+		{"0", true}, // disabled
+	} {
+		validator := RateValidator{Source: source, MaxDeviation: MustParseAmount(test.deviation)}
+		err := validator.ValidateInvoice(context.Background(), store, invoice.ID, invoice.Currency)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("deviation %s: got %v", test.deviation, err)
+		}
+		if err != nil && !errors.Is(err, ErrRateOutOfRange) {
+			t.Errorf("got %v, want ErrRateOutOfRange", err)
+		}
+	}
+
+	// events are checked against the invoice currency
+	if err := (RateValidator{Source: source, MaxDeviation: MustParseAmount("0.03")}).ValidateEvent(context.Background(), store, event); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("got %v, want ErrInvalidEvent", err)
+	}
+
+	// unknown currency pairs and zero reference rates are not checked
+	if err := (RateValidator{Source: StaticRateSource{"XMR_EUR": MustParseAmount("1")}, MaxDeviation: MustParseAmount("0.01")}).ValidateInvoice(context.Background(), store, invoice.ID, invoice.Currency); err != nil {
+		t.Errorf("got %v", err)
+	}
+	if err := (RateValidator{Source: StaticRateSource{"BTC_EUR": Amount{}}, MaxDeviation: MustParseAmount("0.01")}).ValidateInvoice(context.Background(), store, invoice.ID, invoice.Currency); err != nil {
+		t.Errorf("zero reference rate: got %v", err)
+	}
+
+	// payment request events are not checked
+	var other = &PaymentRequestUpdatedEvent{}
+	if err := (RateValidator{MaxRates: map[string]Amount{"BTC": MustParseAmount("1")}}).ValidateEvent(context.Background(), store, other); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestForEventTypes(t *testing.T) {
+
+	var calls int
+	validator := ForEventTypes(EventValidatorFunc(func(ctx context.Context, store StoreContext, event Event) error {
+		calls++
+		return nil
+	}), PaymentEventTypes...)
+
+	var created = &InvoiceCreatedEvent{}
+	created.Type = EventInvoiceCreated
+	var settled = &InvoiceSettledEvent{}
+	settled.Type = EventInvoiceSettled
+
+	for _, event := range []Event{created, settled} {
+		if err := validator.ValidateEvent(context.Background(), nil, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
+
+func TestServerStoreValidators(t *testing.T) {
+
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected API request %s", r.URL.Path)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	// the default rate check does not apply to InvoiceCreated
+	var created = &InvoiceCreatedEvent{}
+	created.Type = EventInvoiceCreated
+	created.StoreID = "test-store"
+	store.MaxRates = map[string]Amount{"BTC": MustParseAmount("1")}
+	if err := store.ValidateEvent(context.Background(), created); err != nil {
+		t.Errorf("got %v", err)
+	}
+
+	created.StoreID = "other-store"
+	if err := store.ValidateEvent(context.Background(), created); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("got %v, want ErrInvalidEvent", err)
+	}
+
+	// rejected events are not returned
+	store.WebhookSecret = "test-secret"
+	event, err := store.ProcessWebhook(newWebhookRequest("test-secret", `{"type": "InvoiceCreated", "storeId": "other-store", "invoiceId": "invoice-1"}`))
+	if event != nil || !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("got %v, %v, want nil and ErrInvalidEvent", event, err)
+	}
+
+	// checks can be disabled
+	store.Validators = []EventValidator{}
+	if err := store.ValidateEvent(context.Background(), created); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
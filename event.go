@@ -1,57 +1,220 @@
 package btcpay
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidEvent     = errors.New("invalid event")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
 type EventType string
 
 // An invoice is considered "settled" if it has been "paid" in time (seen on the blockchain before the invoice time expired) and the full amount has been paid and the transaction has been confirmed (got n confirmations on the blockchain, see SpeedPolicy).
 const (
-	EventInvoiceCreated         EventType = "InvoiceCreated"
-	EventInvoiceExpired         EventType = "InvoiceExpired"
-	EventInvoiceInvalid         EventType = "InvoiceInvalid"
-	EventInvoicePaymentSettled  EventType = "InvoicePaymentSettled"
-	EventInvoiceProcessing      EventType = "InvoiceProcessing"
-	EventInvoiceReceivedPayment EventType = "InvoiceReceivedPayment"
-	EventInvoiceSettled         EventType = "InvoiceSettled"
+	EventInvoiceCreated                EventType = "InvoiceCreated"
+	EventInvoiceExpired                EventType = "InvoiceExpired"
+	EventInvoiceInvalid                EventType = "InvoiceInvalid"
+	EventInvoicePaymentMethodActivated EventType = "InvoicePaymentMethodActivated"
+	EventInvoicePaymentSettled         EventType = "InvoicePaymentSettled"
+	EventInvoiceProcessing             EventType = "InvoiceProcessing"
+	EventInvoiceReceivedPayment        EventType = "InvoiceReceivedPayment"
+	EventInvoiceSettled                EventType = "InvoiceSettled"
+	EventPaymentRequestArchived        EventType = "PaymentRequestArchived"
+	EventPaymentRequestStatusChanged   EventType = "PaymentRequestStatusChanged"
+	EventPaymentRequestUpdated         EventType = "PaymentRequestUpdated"
+	EventPayoutApproved                EventType = "PayoutApproved"
+	EventPayoutCreated                 EventType = "PayoutCreated"
+	EventPayoutUpdated                 EventType = "PayoutUpdated"
 )
 
-type PaymentStatus string
+// An Event is sent by a webhook. It is implemented by the event types of this package only.
+// Use a type switch to get the concrete event, for example *InvoiceSettledEvent. Events of unknown type are returned as *GenericEvent.
+type Event interface {
+	Envelope() *EventEnvelope
+	event()
+}
 
-const (
-	PaymentInvalid    PaymentStatus = "Invalid"
-	PaymentProcessing PaymentStatus = "Processing"
-	PaymentSettled    PaymentStatus = "Settled"
-)
+// EventEnvelope contains the fields which all events have in common.
+type EventEnvelope struct {
+	DeliveryID         string    `json:"deliveryId"`
+	IsRedelivery       bool      `json:"isRedelivery"`
+	OriginalDeliveryID string    `json:"originalDeliveryId"`
+	StoreID            string    `json:"storeId"`
+	Timestamp          UnixTime  `json:"timestamp"`
+	Type               EventType `json:"type"`
+	WebhookID          string    `json:"webhookId"`
+}
+
+// Envelope returns the common fields of the event.
+func (e *EventEnvelope) Envelope() *EventEnvelope {
+	return e
+}
+
+func (*EventEnvelope) event() {}
 
-// An InvoiceEvent is sent by a webhook.
+// An InvoiceEvent contains the fields which all invoice events have in common. It is embedded in the invoice event types.
 // You can find your custom OrderID in InvoiceMetadata or use GetInvoice to obtain the full invoice.
 type InvoiceEvent struct {
-	DeliveryID         string          `json:"deliveryId"`
-	InvoiceID          string          `json:"invoiceId"`
-	IsRedelivery       bool            `json:"isRedelivery"`
-	OriginalDeliveryID string          `json:"originalDeliveryId"`
-	StoreID            string          `json:"storeId"`
-	Timestamp          int64           `json:"timestamp"`
-	Type               EventType       `json:"type"`
-	WebhookID          string          `json:"webhookId"`
-	InvoiceMetadata    InvoiceMetadata `json:"metadata"`
-
-	// InvoiceInvalid and InvoiceSettled only
-	ManuallyMarked bool `json:"manuallyMarked"`
+	EventEnvelope
+	InvoiceID       string          `json:"invoiceId"`
+	InvoiceMetadata InvoiceMetadata `json:"metadata"`
+}
+
+func (e *InvoiceEvent) invoiceEvent() *InvoiceEvent {
+	return e
+}
+
+// AsInvoiceEvent returns the common invoice fields if event is an invoice event.
+func AsInvoiceEvent(event Event) (*InvoiceEvent, bool) {
+	if e, ok := event.(interface{ invoiceEvent() *InvoiceEvent }); ok {
+		return e.invoiceEvent(), true
+	}
+	return nil, false
+}
 
-	// InvoiceReceivedPayment and InvoicePaymentSettled only
-	AfterExpiration bool     `json:"afterExpiration"` // whether this payment has been sent after the invoice expired
-	PaymentMethodID string   `json:"paymentMethodId"` // what payment method was used for this payment
-	Payment         struct { // details about the payment
-		ID           string        `json:"id"`           // a unique identifier for this payment
-		ReceivedDate int           `json:"receivedDate"` // the date the payment was recorded
-		Value        string        `json:"value"`        // the value of the payment
-		Fee          string        `json:"fee"`          // the fee paid for the payment
-		Status       PaymentStatus `json:"status"`       // the status of the payment
-		Destination  string        `json:"destination"`  // the destination the payment was made to
-	} `json:"payment"`
-
-	// InvoiceExpired only
+type InvoiceCreatedEvent struct {
+	InvoiceEvent
+}
+
+type InvoiceExpiredEvent struct {
+	InvoiceEvent
 	PartiallyPaid bool `json:"partiallyPaid"`
+}
+
+type InvoiceInvalidEvent struct {
+	InvoiceEvent
+	ManuallyMarked bool `json:"manuallyMarked"`
+}
+
+type InvoicePaymentMethodActivatedEvent struct {
+	InvoiceEvent
+	PaymentMethodID string `json:"paymentMethodId"`
+}
 
-	// InvoiceProcessing and InvoiceSettled only
+type InvoicePaymentSettledEvent struct {
+	InvoiceEvent
+	AfterExpiration bool           `json:"afterExpiration"` // whether this payment has been sent after the invoice expired
+	PaymentMethodID string         `json:"paymentMethodId"` // what payment method was used for this payment
+	Payment         InvoicePayment `json:"payment"`         // details about the payment
+}
+
+type InvoiceProcessingEvent struct {
+	InvoiceEvent
 	OverPaid bool `json:"overPaid"`
 }
+
+type InvoiceReceivedPaymentEvent struct {
+	InvoiceEvent
+	AfterExpiration bool           `json:"afterExpiration"` // whether this payment has been sent after the invoice expired
+	PaymentMethodID string         `json:"paymentMethodId"` // what payment method was used for this payment
+	Payment         InvoicePayment `json:"payment"`         // details about the payment
+}
+
+type InvoiceSettledEvent struct {
+	InvoiceEvent
+	ManuallyMarked bool `json:"manuallyMarked"`
+	OverPaid       bool `json:"overPaid"`
+}
+
+// A PaymentRequestEvent contains the fields which all payment request events have in common.
+type PaymentRequestEvent struct {
+	EventEnvelope
+	PaymentRequestID string `json:"paymentRequestId"`
+}
+
+type PaymentRequestArchivedEvent struct {
+	PaymentRequestEvent
+}
+
+type PaymentRequestStatusChangedEvent struct {
+	PaymentRequestEvent
+	Status PaymentRequestStatus `json:"status"`
+}
+
+type PaymentRequestUpdatedEvent struct {
+	PaymentRequestEvent
+}
+
+// A PayoutEvent contains the fields which all payout events have in common.
+type PayoutEvent struct {
+	EventEnvelope
+	PayoutID        string `json:"payoutId"`
+	PullPaymentID   string `json:"pullPaymentId"`
+	PaymentMethodID string `json:"paymentMethodId"`
+	PayoutState     string `json:"payoutState"` // example: "AwaitingApproval", "AwaitingPayment", "InProgress", "Completed", "Cancelled"
+}
+
+type PayoutApprovedEvent struct {
+	PayoutEvent
+}
+
+type PayoutCreatedEvent struct {
+	PayoutEvent
+}
+
+type PayoutUpdatedEvent struct {
+	PayoutEvent
+}
+
+// A GenericEvent is returned for event types which are unknown to this package.
+type GenericEvent struct {
+	EventEnvelope
+	Raw json.RawMessage `json:"-"` // the complete event
+}
+
+// DecodeEvent unmarshals a webhook event into the event type which matches its "type" field. Errors wrap ErrInvalidEvent.
+func DecodeEvent(data []byte) (Event, error) {
+
+	var envelope EventEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+
+	var event Event
+	switch envelope.Type {
+	case EventInvoiceCreated:
+		event = &InvoiceCreatedEvent{}
+	case EventInvoiceExpired:
+		event = &InvoiceExpiredEvent{}
+	case EventInvoiceInvalid:
+		event = &InvoiceInvalidEvent{}
+	case EventInvoicePaymentMethodActivated:
+		event = &InvoicePaymentMethodActivatedEvent{}
+	case EventInvoicePaymentSettled:
+		event = &InvoicePaymentSettledEvent{}
+	case EventInvoiceProcessing:
+		event = &InvoiceProcessingEvent{}
+	case EventInvoiceReceivedPayment:
+		event = &InvoiceReceivedPaymentEvent{}
+	case EventInvoiceSettled:
+		event = &InvoiceSettledEvent{}
+	case EventPaymentRequestArchived:
+		event = &PaymentRequestArchivedEvent{}
+	case EventPaymentRequestStatusChanged:
+		event = &PaymentRequestStatusChangedEvent{}
+	case EventPaymentRequestUpdated:
+		event = &PaymentRequestUpdatedEvent{}
+	case EventPayoutApproved:
+		event = &PayoutApprovedEvent{}
+	case EventPayoutCreated:
+		event = &PayoutCreatedEvent{}
+	case EventPayoutUpdated:
+		event = &PayoutUpdatedEvent{}
+	case "":
+		return nil, fmt.Errorf("%w: type missing", ErrInvalidEvent)
+	default:
+		return &GenericEvent{
+			EventEnvelope: envelope,
+			Raw:           append(json.RawMessage(nil), data...),
+		}, nil
+	}
+
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, fmt.Errorf("%w: unmarshaling %s event: %v", ErrInvalidEvent, envelope.Type, err)
+	}
+	return event, nil
+}
@@ -0,0 +1,47 @@
+package btcpay
+
+import (
+	"testing"
+)
+
+func TestDecodeEvent(t *testing.T) {
+
+	event, err := DecodeEvent([]byte(`{"deliveryId": "delivery-1", "type": "InvoiceReceivedPayment", "storeId": "store-1", "invoiceId": "invoice-1", "afterExpiration": true, "paymentMethodId": "BTC", "payment": {"id": "payment-1", "value": "0.001", "status": "Processing"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, ok := event.(*InvoiceReceivedPaymentEvent)
+	if !ok {
+		t.Fatalf("got %T", event)
+	}
+	if payment.DeliveryID != "delivery-1" || payment.InvoiceID != "invoice-1" || !payment.AfterExpiration || payment.Payment.Value.String() != "0.001" {
+		t.Errorf("got %+v", payment)
+	}
+	if invoiceEvent, ok := AsInvoiceEvent(event); !ok || invoiceEvent.InvoiceID != "invoice-1" {
+		t.Errorf("AsInvoiceEvent: got %v, %t", invoiceEvent, ok)
+	}
+
+	event, err = DecodeEvent([]byte(`{"type": "PaymentRequestStatusChanged", "storeId": "store-1", "paymentRequestId": "request-1", "status": "Completed"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, ok := event.(*PaymentRequestStatusChangedEvent); !ok || changed.PaymentRequestID != "request-1" || changed.Status != PaymentRequestCompleted {
+		t.Errorf("got %+v", event)
+	}
+	if _, ok := AsInvoiceEvent(event); ok {
+		t.Errorf("payment request event is an invoice event")
+	}
+
+	var unknown = []byte(`{"type": "SomethingNew", "storeId": "store-1", "foo": "bar"}`)
+	event, err = DecodeEvent(unknown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generic, ok := event.(*GenericEvent); !ok || generic.Type != "SomethingNew" || string(generic.Raw) != string(unknown) {
+		t.Errorf("got %+v", event)
+	}
+
+	if _, err := DecodeEvent([]byte(`{"storeId": "store-1"}`)); err == nil {
+		t.Errorf("missing type: got no error")
+	}
+}
@@ -0,0 +1,112 @@
+package btcpay
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultPageSize is used by InvoiceIterator if InvoiceFilter.Take is zero.
+const DefaultPageSize = 100
+
+// InvoiceFilter contains the query parameters of ListInvoices. Empty fields are ignored.
+type InvoiceFilter struct {
+	OrderIDs        []string        // invoices with any of these order IDs
+	Statuses        []InvoiceStatus // invoices with any of these statuses
+	TextSearch      string          // full text search in invoice fields
+	StartDate       time.Time       // invoices created at or after StartDate
+	EndDate         time.Time       // invoices created at or before EndDate
+	IncludeArchived bool
+	Skip            int // number of invoices to skip
+	Take            int // maximum number of invoices to return
+}
+
+func (f *InvoiceFilter) query() url.Values {
+	var query = url.Values{}
+	if f == nil {
+		return query
+	}
+	for _, orderID := range f.OrderIDs {
+		query.Add("orderId", orderID)
+	}
+	for _, status := range f.Statuses {
+		query.Add("status", string(status))
+	}
+	if f.TextSearch != "" {
+		query.Set("textSearch", f.TextSearch)
+	}
+	if !f.StartDate.IsZero() {
+		query.Set("startDate", strconv.FormatInt(f.StartDate.Unix(), 10))
+	}
+	if !f.EndDate.IsZero() {
+		query.Set("endDate", strconv.FormatInt(f.EndDate.Unix(), 10))
+	}
+	if f.IncludeArchived {
+		query.Set("includeArchived", "true")
+	}
+	if f.Skip > 0 {
+		query.Set("skip", strconv.Itoa(f.Skip))
+	}
+	if f.Take > 0 {
+		query.Set("take", strconv.Itoa(f.Take))
+	}
+	return query
+}
+
+// InvoiceIterator pages through all invoices matching a filter. Use it like a bufio.Scanner:
+//
+//	it := store.IterateInvoicesContext(ctx, filter)
+//	for it.Next() {
+//		invoice := it.Invoice()
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle error
+//	}
+type InvoiceIterator struct {
+	ctx    context.Context
+	store  *ServerStore
+	filter InvoiceFilter
+	page   []Invoice
+	index  int
+	done   bool
+	err    error
+}
+
+// Next advances the iterator to the next invoice, fetching the next page if required.
+// It returns false when there are no more invoices or an error occurred.
+func (it *InvoiceIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.index++
+	if it.index < len(it.page) {
+		return true
+	}
+	if it.done {
+		return false
+	}
+
+	page, err := it.store.ListInvoicesContext(it.ctx, &it.filter)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page = page
+	it.index = 0
+	it.filter.Skip += len(page)
+	if len(page) < it.filter.Take {
+		it.done = true
+	}
+	return len(page) > 0
+}
+
+// Invoice returns the current invoice. It is valid until the next call to Next.
+func (it *InvoiceIterator) Invoice() *Invoice {
+	return &it.page[it.index]
+}
+
+// Err returns the first error which occurred during iteration.
+func (it *InvoiceIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,171 @@
+package btcpay
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// InvoiceMetadata contains the well-known metadata fields of the Greenfield API. Other fields are kept in Extra.
+type InvoiceMetadata struct {
+	OrderID       string          `json:"orderId,omitempty"`  // OrderID is stored in the invoice list of your BTCPay server. If the invoice has been created through a payment request, this is the ID of the payment request.
+	OrderURL      string          `json:"orderUrl,omitempty"` // link to the order in your shop
+	PosData       json.RawMessage `json:"posData,omitempty"`  // point of sale data, usually a JSON object or string
+	BuyerName     string          `json:"buyerName,omitempty"`
+	BuyerEmail    string          `json:"buyerEmail,omitempty"`
+	BuyerCountry  string          `json:"buyerCountry,omitempty"`
+	BuyerZip      string          `json:"buyerZip,omitempty"`
+	BuyerState    string          `json:"buyerState,omitempty"`
+	BuyerCity     string          `json:"buyerCity,omitempty"`
+	BuyerAddress1 string          `json:"buyerAddress1,omitempty"`
+	BuyerAddress2 string          `json:"buyerAddress2,omitempty"`
+	BuyerPhone    string          `json:"buyerPhone,omitempty"`
+	ItemDesc      string          `json:"itemDesc,omitempty"`
+	ItemCode      string          `json:"itemCode,omitempty"`
+	Physical      *bool           `json:"physical,omitempty"`
+	TaxIncluded   json.Number     `json:"taxIncluded,omitempty"` // example: 0.8
+
+	// Extra contains all other metadata fields. They are preserved when marshaling and unmarshaling.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// invoiceMetadata has no methods, so it is marshaled by encoding/json as usual.
+type invoiceMetadata InvoiceMetadata
+
+// invoiceMetadataKeys contains the JSON keys of the well-known fields.
+var invoiceMetadataKeys = jsonKeys(reflect.TypeOf(invoiceMetadata{}))
+
+func jsonKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+func (m InvoiceMetadata) MarshalJSON() ([]byte, error) {
+	known, err := json.Marshal(invoiceMetadata(m))
+	if err != nil || len(m.Extra) == 0 {
+		return known, err
+	}
+	var fields = make(map[string]json.RawMessage, len(m.Extra)+len(invoiceMetadataKeys))
+	for key, value := range m.Extra {
+		fields[key] = value
+	}
+	// well-known fields take precedence over Extra
+	if err := json.Unmarshal(known, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+func (m *InvoiceMetadata) UnmarshalJSON(data []byte) error {
+	var known invoiceMetadata
+	if err := json.Unmarshal(data, &known); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, key := range invoiceMetadataKeys {
+		delete(fields, key)
+	}
+	if len(fields) > 0 {
+		known.Extra = fields
+	}
+	*m = InvoiceMetadata(known)
+	return nil
+}
+
+// invoiceRequestJSON is the JSON representation of InvoiceRequest. InvoiceMetadata is embedded in InvoiceRequest,
+// so its JSON methods would be promoted to InvoiceRequest and Invoice. Here it is a named field instead.
+type invoiceRequestJSON struct {
+	Amount   Amount          `json:"amount"`
+	Currency string          `json:"currency"`
+	Metadata InvoiceMetadata `json:"metadata"`
+	Checkout InvoiceCheckout `json:"checkout"`
+	Receipt  *InvoiceReceipt `json:"receipt,omitempty"`
+}
+
+func newInvoiceRequestJSON(req InvoiceRequest) invoiceRequestJSON {
+	return invoiceRequestJSON{
+		Amount:   req.Amount,
+		Currency: req.Currency,
+		Metadata: req.InvoiceMetadata,
+		Checkout: req.InvoiceCheckout,
+		Receipt:  req.Receipt,
+	}
+}
+
+func (req invoiceRequestJSON) invoiceRequest() InvoiceRequest {
+	return InvoiceRequest{
+		Amount:          req.Amount,
+		Currency:        req.Currency,
+		InvoiceMetadata: req.Metadata,
+		InvoiceCheckout: req.Checkout,
+		Receipt:         req.Receipt,
+	}
+}
+
+func (req InvoiceRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newInvoiceRequestJSON(req))
+}
+
+func (req *InvoiceRequest) UnmarshalJSON(data []byte) error {
+	var v invoiceRequestJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*req = v.invoiceRequest()
+	return nil
+}
+
+// invoiceJSON is the JSON representation of Invoice. It must be kept in sync with Invoice, which would use the JSON methods of InvoiceRequest otherwise.
+type invoiceJSON struct {
+	invoiceRequestJSON
+	ID                   string                  `json:"id"`
+	CheckoutLink         string                  `json:"checkoutLink"`
+	CreatedTime          UnixTime                `json:"createdTime"`
+	ExpirationTime       UnixTime                `json:"expirationTime"`
+	MonitoringExpiration UnixTime                `json:"monitoringExpiration"`
+	Status               InvoiceStatus           `json:"status"`
+	AdditionalStatus     InvoiceAdditionalStatus `json:"additionalStatus"`
+	Archived             bool                    `json:"archived"`
+}
+
+func (invoice Invoice) MarshalJSON() ([]byte, error) {
+	return json.Marshal(invoiceJSON{
+		invoiceRequestJSON:   newInvoiceRequestJSON(invoice.InvoiceRequest),
+		ID:                   invoice.ID,
+		CheckoutLink:         invoice.CheckoutLink,
+		CreatedTime:          invoice.CreatedTime,
+		ExpirationTime:       invoice.ExpirationTime,
+		MonitoringExpiration: invoice.MonitoringExpiration,
+		Status:               invoice.Status,
+		AdditionalStatus:     invoice.AdditionalStatus,
+		Archived:             invoice.Archived,
+	})
+}
+
+func (invoice *Invoice) UnmarshalJSON(data []byte) error {
+	var v invoiceJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*invoice = Invoice{
+		InvoiceRequest:       v.invoiceRequest(),
+		ID:                   v.ID,
+		CheckoutLink:         v.CheckoutLink,
+		CreatedTime:          v.CreatedTime,
+		ExpirationTime:       v.ExpirationTime,
+		MonitoringExpiration: v.MonitoringExpiration,
+		Status:               v.Status,
+		AdditionalStatus:     v.AdditionalStatus,
+		Archived:             v.Archived,
+	}
+	return nil
+}
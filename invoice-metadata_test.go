@@ -0,0 +1,63 @@
+package btcpay
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestInvoiceMetadata(t *testing.T) {
+
+	var input = []byte(`{"amount": "5", "currency": "EUR", "metadata": {"orderId": "order-1", "buyerEmail": "buyer@example.com", "physical": false, "taxIncluded": 0.8, "posData": {"cart": [1, 2]}, "custom": {"a": "b"}}}`)
+
+	var invoice Invoice
+	if err := json.Unmarshal(input, &invoice); err != nil {
+		t.Fatal(err)
+	}
+	metadata := invoice.InvoiceMetadata
+	if metadata.OrderID != "order-1" || metadata.BuyerEmail != "buyer@example.com" || metadata.TaxIncluded != "0.8" || metadata.Physical == nil || *metadata.Physical {
+		t.Errorf("got %+v", metadata)
+	}
+	if string(metadata.PosData) != `{"cart": [1, 2]}` {
+		t.Errorf("got posData %s", metadata.PosData)
+	}
+	if len(metadata.Extra) != 1 || string(metadata.Extra["custom"]) != `{"a": "b"}` {
+		t.Errorf("got extra %v", metadata.Extra)
+	}
+
+	data, err := json.Marshal(invoice.InvoiceRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip struct {
+		Metadata map[string]json.RawMessage `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	if len(roundTrip.Metadata) != 6 || string(roundTrip.Metadata["custom"]) != `{"a":"b"}` {
+		t.Errorf("got %s", data)
+	}
+	if string(roundTrip.Metadata["physical"]) != "false" || string(roundTrip.Metadata["taxIncluded"]) != "0.8" {
+		t.Errorf("got %s", data)
+	}
+
+	if invoice.OrderID != "order-1" {
+		t.Errorf("got OrderID %s", invoice.OrderID)
+	}
+	if !strings.Contains(string(data), `"amount":"5"`) {
+		t.Errorf("got %s", data)
+	}
+
+	data, err = json.Marshal(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTripInvoice Invoice
+	if err := json.Unmarshal(data, &roundTripInvoice); err != nil {
+		t.Fatal(err)
+	}
+	if roundTripInvoice.OrderID != "order-1" || len(roundTripInvoice.InvoiceMetadata.Extra) != 1 {
+		t.Errorf("got %s", data)
+	}
+}
@@ -0,0 +1,75 @@
+package btcpay
+
+// InvoiceStatus is the main status of an invoice.
+type InvoiceStatus string
+
+const (
+	InvoiceNew        InvoiceStatus = "New"        // not paid yet
+	InvoiceProcessing InvoiceStatus = "Processing" // paid in full, but not confirmed yet according to SpeedPolicy
+	InvoiceExpired    InvoiceStatus = "Expired"    // not paid in full before the expiration time
+	InvoiceInvalid    InvoiceStatus = "Invalid"    // payment failed to confirm, or marked invalid manually
+	InvoiceSettled    InvoiceStatus = "Settled"    // paid in full and confirmed, or marked settled manually
+)
+
+// invoiceTransitions contains the status changes which BTCPay Server performs, either automatically or by manual marking.
+var invoiceTransitions = map[InvoiceStatus][]InvoiceStatus{
+	InvoiceNew:        {InvoiceProcessing, InvoiceExpired, InvoiceInvalid, InvoiceSettled},
+	InvoiceProcessing: {InvoiceInvalid, InvoiceSettled},
+	InvoiceExpired:    {InvoiceInvalid, InvoiceSettled}, // manually marked
+	InvoiceInvalid:    {InvoiceSettled},                 // manually marked
+	InvoiceSettled:    {InvoiceInvalid},                 // manually marked
+}
+
+// Valid returns true if s is a known invoice status.
+func (s InvoiceStatus) Valid() bool {
+	_, ok := invoiceTransitions[s]
+	return ok
+}
+
+// IsFinal returns true if the status won't change any more, unless the invoice is marked manually.
+// Note that expired invoices can still receive late payments, see InvoiceAdditionalPaidLate.
+func (s InvoiceStatus) IsFinal() bool {
+	switch s {
+	case InvoiceExpired, InvoiceInvalid, InvoiceSettled:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsPaid returns true if the invoice has been paid in full in time. The payment might not be confirmed yet (InvoiceProcessing).
+func (s InvoiceStatus) IsPaid() bool {
+	return s == InvoiceProcessing || s == InvoiceSettled
+}
+
+// CanTransitionTo returns true if an invoice can change from status s to next.
+func (s InvoiceStatus) CanTransitionTo(next InvoiceStatus) bool {
+	for _, allowed := range invoiceTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// InvoiceAdditionalStatus gives details about the invoice status.
+type InvoiceAdditionalStatus string
+
+const (
+	InvoiceAdditionalNone        InvoiceAdditionalStatus = "None"
+	InvoiceAdditionalPaidLate    InvoiceAdditionalStatus = "PaidLate"    // paid after the invoice expired
+	InvoiceAdditionalPaidPartial InvoiceAdditionalStatus = "PaidPartial" // not paid in full
+	InvoiceAdditionalMarked      InvoiceAdditionalStatus = "Marked"      // status has been set manually
+	InvoiceAdditionalInvalid     InvoiceAdditionalStatus = "Invalid"     // payment failed to confirm
+	InvoiceAdditionalPaidOver    InvoiceAdditionalStatus = "PaidOver"    // paid more than the invoice amount
+)
+
+// Valid returns true if s is a known additional invoice status.
+func (s InvoiceAdditionalStatus) Valid() bool {
+	switch s {
+	case InvoiceAdditionalNone, InvoiceAdditionalPaidLate, InvoiceAdditionalPaidPartial, InvoiceAdditionalMarked, InvoiceAdditionalInvalid, InvoiceAdditionalPaidOver:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,38 @@
+package btcpay
+
+import (
+	"testing"
+)
+
+func TestInvoiceStatus(t *testing.T) {
+
+	tests := []struct {
+		from, to InvoiceStatus
+		want     bool
+	}{
+		{InvoiceNew, InvoiceProcessing, true},
+		{InvoiceNew, InvoiceExpired, true},
+		{InvoiceProcessing, InvoiceSettled, true},
+		{InvoiceProcessing, InvoiceNew, false},
+		{InvoiceExpired, InvoiceSettled, true},
+		{InvoiceSettled, InvoiceInvalid, true},
+		{InvoiceSettled, InvoiceProcessing, false},
+		{InvoiceSettled, InvoiceSettled, false},
+		{"Unknown", InvoiceSettled, false},
+	}
+	for _, test := range tests {
+		if got := test.from.CanTransitionTo(test.to); got != test.want {
+			t.Errorf("%s -> %s: got %t, want %t", test.from, test.to, got, test.want)
+		}
+	}
+
+	if InvoiceNew.IsFinal() || InvoiceProcessing.IsFinal() || !InvoiceSettled.IsFinal() {
+		t.Error("IsFinal failed")
+	}
+	if InvoiceNew.IsPaid() || !InvoiceProcessing.IsPaid() || InvoiceExpired.IsPaid() {
+		t.Error("IsPaid failed")
+	}
+	if InvoiceStatus("Complete").Valid() || !InvoiceAdditionalPaidOver.Valid() {
+		t.Error("Valid failed")
+	}
+}
@@ -1,16 +1,19 @@
 package btcpay
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
+	"time"
 )
 
+var ErrOrderIDMissing = errors.New("order ID missing")
+
+// CheckoutType selects the checkout page design.
+type CheckoutType string
+
 const (
-	InvoiceNew        string = "New"
-	InvoiceProcessing string = "Processing"
-	InvoiceExpired    string = "Expired"
-	InvoiceInvalid    string = "Invalid"
-	InvoiceSettled    string = "Settled"
+	CheckoutV1 CheckoutType = "V1"
+	CheckoutV2 CheckoutType = "V2"
 )
 
 // SpeedPolicy defines when an invoice is considered confirmed.
@@ -25,24 +28,70 @@ const (
 
 type Invoice struct {
 	InvoiceRequest
-	ID                   string `json:"id"`
-	CheckoutLink         string `json:"checkoutLink"`
-	CreatedTime          int64  `json:"createdTime"`
-	ExpirationTime       int64  `json:"expirationTime"`
-	MonitoringExpiration int64  `json:"monitoringExpiration"`
-	Status               string `json:"status"`
-	AdditionalStatus     string `json:"additionalStatus"`
+	ID                   string                  `json:"id"`
+	CheckoutLink         string                  `json:"checkoutLink"`
+	CreatedTime          UnixTime                `json:"createdTime"`
+	ExpirationTime       UnixTime                `json:"expirationTime"`
+	MonitoringExpiration UnixTime                `json:"monitoringExpiration"`
+	Status               InvoiceStatus           `json:"status"`
+	AdditionalStatus     InvoiceAdditionalStatus `json:"additionalStatus"`
+	Archived             bool                    `json:"archived"`
 }
 
-type InvoiceRequest struct {
-	Amount          float64 `json:"amount,string"`
-	Currency        string  `json:"currency"`
-	InvoiceMetadata `json:"metadata,omitempty"`
-	InvoiceCheckout `json:"checkout,omitempty"`
+// IsExpired returns true if the invoice expiration time has been reached at the given time.
+// Note that payments which have been sent before are still accepted until MonitoringExpiration.
+func (invoice *Invoice) IsExpired(now time.Time) bool {
+	return !now.Before(invoice.ExpirationTime.Time)
+}
+
+// TimeLeft returns the duration until the invoice expires, or zero if it has expired.
+func (invoice *Invoice) TimeLeft(now time.Time) time.Duration {
+	if left := invoice.ExpirationTime.Sub(now); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// ManuallyMarked returns true if the invoice status has been set manually, e.g. using MarkInvoiceStatus.
+func (invoice *Invoice) ManuallyMarked() bool {
+	return invoice.AdditionalStatus == InvoiceAdditionalMarked
+}
+
+// checkMarkStatus returns an error if an invoice can't be marked with the given status.
+func checkMarkStatus(status InvoiceStatus) error {
+	switch status {
+	case InvoiceSettled, InvoiceInvalid:
+		return nil
+	default:
+		return fmt.Errorf("invoices can be marked as %s or %s only, not as %s", InvoiceSettled, InvoiceInvalid, status)
+	}
 }
 
-type InvoiceMetadata struct {
-	OrderID string `json:"orderId,omitempty"` // OrderID is stored in the invoice list of your BTCPay server. If the invoice has been created through a payment request, this is the ID of the payment request.
+// findReusableInvoice returns the first invoice with the given order ID which is processing or settled, or new and not expired at the given time, or nil.
+// New invoices can be expired before BTCPay Server updates their status.
+func findReusableInvoice(invoices []Invoice, orderID string, now time.Time) *Invoice {
+	for i := range invoices {
+		if invoices[i].InvoiceMetadata.OrderID != orderID {
+			continue
+		}
+		switch invoices[i].Status {
+		case InvoiceNew:
+			if invoices[i].ExpirationTime.IsZero() || !invoices[i].IsExpired(now) {
+				return &invoices[i]
+			}
+		case InvoiceProcessing, InvoiceSettled:
+			return &invoices[i]
+		}
+	}
+	return nil
+}
+
+type InvoiceRequest struct {
+	Amount          Amount `json:"amount"`
+	Currency        string `json:"currency"`
+	InvoiceMetadata `json:"metadata"`
+	InvoiceCheckout `json:"checkout,omitempty"`
+	Receipt         *InvoiceReceipt `json:"receipt,omitempty"` // nil means store settings
 }
 
 type InvoiceCheckout struct {
@@ -53,44 +102,94 @@ type InvoiceCheckout struct {
 	PaymentTolerance  float64     `json:"paymentTolerance,omitempty"`
 	RedirectURL       string      `json:"redirectURL,omitempty"`     // RedirectURL is stored in the invoice list of your BTCPay server and used as href behind OrderID.
 	DefaultLanguage   string      `json:"defaultLanguage,omitempty"` // see https://github.com/btcpayserver/btcpayserver/tree/master/BTCPayServer/wwwroot/locales
+
+	// nil means store setting
+	RequiresRefundEmail   *bool        `json:"requiresRefundEmail,omitempty"`   // ask the customer for an email address for refunds
+	LazyPaymentMethods    *bool        `json:"lazyPaymentMethods,omitempty"`    // generate payment destinations on demand only, see ActivateInvoicePaymentMethod
+	RedirectAutomatically *bool        `json:"redirectAutomatically,omitempty"` // redirect to RedirectURL after the invoice has been paid
+	CheckoutType          CheckoutType `json:"checkoutType,omitempty"`
+	DefaultPaymentMethod  string       `json:"defaultPaymentMethod,omitempty"` // payment method which is selected first on the checkout page, example: "BTC"
+}
+
+// InvoiceReceipt configures the receipt page of an invoice, see ReceiptLink. Nil fields mean store settings.
+type InvoiceReceipt struct {
+	Enabled      *bool `json:"enabled,omitempty"`
+	ShowQR       *bool `json:"showQR,omitempty"`       // show a QR code of the receipt link
+	ShowPayments *bool `json:"showPayments,omitempty"` // list the payments on the receipt
 }
 
 type InvoicePaymentMethod struct {
-	PaymentMethod     string `json:"paymentMethod"` // example: "XMR"
-	CryptoCode        string `json:"cryptoCode"`    // example: "XMR"
-	Destination       string `json:"destination"`
-	PaymentLink       string `json:"paymentLink"`
-	Rate              string `json:"rate"`              // example: "122.7738548555"
-	PaymentMethodPaid string `json:"paymentMethodPaid"` // example: "0.03665275"
-	TotalPaid         string `json:"totalPaid"`         // Total invoice payment, converted into this currency. This is greater than zero even if there is no payment in this crypto. Be careful!
-	Due               string `json:"due"`               // example: "0"
-	Amount            string `json:"amount"`            // Some amount, converted into this currency. This is greater than zero even if there is no payment in this crypto. Be careful!
-	NetworkFee        string `json:"networkFee"`
-	Payments          []struct {
-		ID           string `json:"id"`
-		ReceivedDate int    `json:"receivedDate"` // unix timestamp
-		Value        string `json:"value"`        // example: "0.036652750000"
-		Fee          string `json:"fee"`          // example: "0.0000000003"
-		Status       string `json:"status"`       // example: "Settled"
-		Destination  string `json:"destination"`
-	} `json:"payments"`
-	Activated      bool `json:"activated"`
-	AdditionalData struct {
+	PaymentMethod     string           `json:"paymentMethod"` // example: "XMR"
+	CryptoCode        string           `json:"cryptoCode"`    // example: "XMR"
+	Destination       string           `json:"destination"`
+	PaymentLink       string           `json:"paymentLink"`
+	Rate              Amount           `json:"rate"`              // example: "122.7738548555"
+	PaymentMethodPaid Amount           `json:"paymentMethodPaid"` // example: "0.03665275"
+	TotalPaid         Amount           `json:"totalPaid"`         // Total invoice payment, converted into this currency. This is greater than zero even if there is no payment in this crypto. Be careful!
+	Due               Amount           `json:"due"`               // example: "0"
+	Amount            Amount           `json:"amount"`            // Some amount, converted into this currency. This is greater than zero even if there is no payment in this crypto. Be careful!
+	NetworkFee        Amount           `json:"networkFee"`
+	Payments          []InvoicePayment `json:"payments"`
+	Activated         bool             `json:"activated"`
+	AdditionalData    struct {
 		ProvidedComment          string `json:"providedComment"`
 		ConsumedLightningAddress string `json:"consumedLightningAddress"`
 	} `json:"additionalData"`
 }
 
-// ValidateRates returns an error if the exchange rate for the given cryptoCode is above the max rate.
-func ValidateRate(methods []InvoicePaymentMethod, cryptoCode string, maxRate float64) error {
+type PaymentStatus string
+
+const (
+	PaymentInvalid    PaymentStatus = "Invalid"
+	PaymentProcessing PaymentStatus = "Processing"
+	PaymentSettled    PaymentStatus = "Settled"
+)
+
+// An InvoicePayment is a payment to an invoice. It is returned by GetInvoicePaymentMethods and sent in payment webhook events.
+type InvoicePayment struct {
+	ID           string        `json:"id"`           // a unique identifier for this payment
+	ReceivedDate UnixTime      `json:"receivedDate"` // the date the payment was recorded
+	Value        Amount        `json:"value"`        // the value of the payment, example: "0.036652750000"
+	Fee          Amount        `json:"fee"`          // the fee paid for the payment, example: "0.0000000003"
+	Status       PaymentStatus `json:"status"`       // the status of the payment
+	Destination  string        `json:"destination"`  // the destination the payment was made to
+}
+
+// ValidateRate returns an error if the exchange rate for the given cryptoCode is above the max rate.
+func ValidateRate(methods []InvoicePaymentMethod, cryptoCode string, maxRate Amount) error {
 	for _, method := range methods {
 		if method.CryptoCode == cryptoCode {
-			rate, err := strconv.ParseFloat(method.Rate, 64)
-			if err != nil {
-				return err
+			if method.Rate.Cmp(maxRate) > 0 {
+				return fmt.Errorf("%s rate %s exceeds max rate %s", method.CryptoCode, method.Rate.StringFixed(2), maxRate.StringFixed(2))
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateMinRate returns an error if the exchange rate for the given cryptoCode is below the min rate.
+// Payment methods without a rate (zero) are not checked.
+func ValidateMinRate(methods []InvoicePaymentMethod, cryptoCode string, minRate Amount) error {
+	for _, method := range methods {
+		if method.CryptoCode == cryptoCode && method.Rate.Sign() > 0 {
+			if method.Rate.Cmp(minRate) < 0 {
+				return fmt.Errorf("%s rate %s is below min rate %s", method.CryptoCode, method.Rate.StringFixed(2), minRate.StringFixed(2))
 			}
-			if rate > maxRate {
-				return fmt.Errorf("%s rate %.2f exceeds max rate %.2f", method.CryptoCode, rate, maxRate)
+		}
+	}
+	return nil
+}
+
+// ValidateRateDeviation returns an error if the exchange rate for the given cryptoCode deviates from the reference rate by more than maxDeviation (relative, example: 0.05 for 5 %).
+// If the reference rate is not positive, it returns an error wrapping ErrRateUnavailable. Payment methods without a rate (zero) are not checked.
+func ValidateRateDeviation(methods []InvoicePaymentMethod, cryptoCode string, reference Amount, maxDeviation Amount) error {
+	if reference.Sign() <= 0 {
+		return fmt.Errorf("%w: %s reference rate is %s", ErrRateUnavailable, cryptoCode, reference)
+	}
+	for _, method := range methods {
+		if method.CryptoCode == cryptoCode && method.Rate.Sign() > 0 {
+			if method.Rate.Sub(reference).Abs().Cmp(reference.Mul(maxDeviation).Abs()) > 0 {
+				return fmt.Errorf("%s rate %s deviates from reference rate %s by more than %s", method.CryptoCode, method.Rate.StringFixed(2), reference.StringFixed(2), maxDeviation)
 			}
 		}
 	}
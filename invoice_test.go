@@ -0,0 +1,75 @@
+package btcpay
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInvoiceRequestCheckout(t *testing.T) {
+
+	var yes, no = true, false
+	req := &InvoiceRequest{
+		Amount:   NewAmount(5, 0),
+		Currency: "EUR",
+		InvoiceCheckout: InvoiceCheckout{
+			RequiresRefundEmail:  &yes,
+			LazyPaymentMethods:   &no,
+			CheckoutType:         CheckoutV2,
+			DefaultPaymentMethod: "BTC",
+		},
+		Receipt: &InvoiceReceipt{
+			Enabled: &yes,
+			ShowQR:  &no,
+		},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Checkout map[string]json.RawMessage `json:"checkout"`
+		Receipt  map[string]json.RawMessage `json:"receipt"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Checkout) != 4 || string(got.Checkout["requiresRefundEmail"]) != "true" || string(got.Checkout["lazyPaymentMethods"]) != "false" || string(got.Checkout["checkoutType"]) != `"V2"` || string(got.Checkout["defaultPaymentMethod"]) != `"BTC"` {
+		t.Errorf("got checkout %s", data)
+	}
+	if _, ok := got.Checkout["redirectAutomatically"]; ok {
+		t.Errorf("nil redirectAutomatically has been sent: %s", data)
+	}
+	if len(got.Receipt) != 2 || string(got.Receipt["enabled"]) != "true" || string(got.Receipt["showQR"]) != "false" {
+		t.Errorf("got receipt %s", data)
+	}
+
+	req.Receipt = nil
+	data, err = json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["receipt"]; ok {
+		t.Errorf("nil receipt has been sent: %s", data)
+	}
+}
+
+func TestValidateMinRate(t *testing.T) {
+
+	var methods = []InvoicePaymentMethod{
+		{CryptoCode: "BTC", Rate: MustParseAmount("50000")},
+		{CryptoCode: "XMR"}, // rate missing
+	}
+	if err := ValidateMinRate(methods, "BTC", MustParseAmount("40000")); err != nil {
+		t.Errorf("got %v", err)
+	}
+	if err := ValidateMinRate(methods, "BTC", MustParseAmount("60000")); err == nil {
+		t.Error("rate below min rate has been accepted")
+	}
+	if err := ValidateMinRate(methods, "XMR", MustParseAmount("100")); err != nil {
+		t.Errorf("missing rate: got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package btcpay
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -15,24 +16,44 @@ const (
 type PaymentRequest struct {
 	PaymentRequestRequest
 	Archived bool                 `json:"archived"`
-	Created  string               `json:"created"`
+	Created  RFC3339Time          `json:"created"`
 	ID       string               `json:"id"`
 	Status   PaymentRequestStatus `json:"status"`
 }
 
 // Mandatory fields are amount, currency and title.
 type PaymentRequestRequest struct {
-	AllowCustomPaymentAmounts bool    `json:"allowCustomPaymentAmounts,omitempty"`
-	Amount                    float64 `json:"amount"`
-	Currency                  string  `json:"currency"`                // ISO 4217 Currency code (BTC, EUR, USD, etc)
-	CustomCSSLink             string  `json:"customCSSLink,omitempty"` // URI
-	Description               string  `json:"description,omitempty"`   // HTML
-	Email                     string  `json:"email,omitempty"`
-	EmbeddedCSS               string  `json:"embeddedCSS,omitempty"` // CSS up to 500 bytes
-	ExpiryDate                string  `json:"expiryDate,omitempty"`  // RFC3339 date (in contrast to the docs which say int64)
-	Title                     string  `json:"title"`                 // required
+	AllowCustomPaymentAmounts bool         `json:"allowCustomPaymentAmounts,omitempty"`
+	Amount                    Amount       `json:"amount"`
+	Currency                  string       `json:"currency"`                // ISO 4217 Currency code (BTC, EUR, USD, etc)
+	CustomCSSLink             string       `json:"customCSSLink,omitempty"` // URI
+	Description               string       `json:"description,omitempty"`   // HTML
+	Email                     string       `json:"email,omitempty"`
+	EmbeddedCSS               string       `json:"embeddedCSS,omitempty"` // CSS up to 500 bytes
+	ExpiryDate                *RFC3339Time `json:"expiryDate,omitempty"`  // RFC3339 date (in contrast to the docs which say int64), nil means no expiry
+	Title                     string       `json:"title"`                 // required
 }
 
 func (req *PaymentRequestRequest) SetExpiryDays(days int) {
-	req.ExpiryDate = time.Now().AddDate(0, 0, days).Format(time.RFC3339)
+	expiryDate := NewRFC3339Time(time.Now().AddDate(0, 0, days).Truncate(time.Second))
+	req.ExpiryDate = &expiryDate
+}
+
+// IsExpired returns true if the payment request has an expiry date which has been reached at the given time.
+func (pr *PaymentRequest) IsExpired(now time.Time) bool {
+	return pr.ExpiryDate != nil && !pr.ExpiryDate.IsZero() && !now.Before(pr.ExpiryDate.Time)
+}
+
+// checkPaymentAmount returns an error if amount must not be paid for the payment request. Zero means the amount due.
+func (pr *PaymentRequest) checkPaymentAmount(amount Amount) error {
+	if amount.IsZero() {
+		return nil
+	}
+	if !pr.AllowCustomPaymentAmounts && amount.Cmp(pr.Amount) != 0 {
+		return fmt.Errorf("%w: payment request does not allow custom payment amounts", ErrValidation)
+	}
+	if amount.Sign() < 0 {
+		return fmt.Errorf("%w: negative amount", ErrValidation)
+	}
+	return nil
 }
@@ -0,0 +1,43 @@
+package btcpay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrRateUnavailable = errors.New("rate unavailable")
+
+// A RateSource provides reference exchange rates, which are independent of the rate provider of the BTCPay Server store, see ServerStore.RateSource.
+type RateSource interface {
+	// Rate returns the price of one unit of cryptoCode in currency. It returns an error wrapping ErrRateUnavailable if the source doesn't know the currency pair.
+	Rate(ctx context.Context, cryptoCode string, currency string) (Amount, error)
+}
+
+// StaticRateSource is a RateSource with fixed rates. The key is the currency pair, example: {"BTC_EUR": "50000", "XMR_EUR": "150"}.
+type StaticRateSource map[string]Amount
+
+// LoadStaticRateSource unmarshals a json file into a StaticRateSource.
+func LoadStaticRateSource(jsonPath string) (StaticRateSource, error) {
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	var source = StaticRateSource{}
+	if err := json.Unmarshal(data, &source); err != nil {
+		return nil, fmt.Errorf("unmarshaling %s: %w", jsonPath, err)
+	}
+	return source, nil
+}
+
+func (source StaticRateSource) Rate(ctx context.Context, cryptoCode string, currency string) (Amount, error) {
+	var pair = strings.ToUpper(cryptoCode + "_" + currency)
+	rate, ok := source[pair]
+	if !ok {
+		return Amount{}, fmt.Errorf("%w: %s", ErrRateUnavailable, pair)
+	}
+	return rate, nil
+}
@@ -0,0 +1,28 @@
+package btcpay
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticRateSource(t *testing.T) {
+
+	var path = filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"BTC_EUR": "50000.5", "XMR_EUR": 150}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := LoadStaticRateSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate, err := source.Rate(context.Background(), "btc", "EUR"); err != nil || rate.String() != "50000.5" {
+		t.Errorf("got %s, %v", rate, err)
+	}
+	if _, err := source.Rate(context.Background(), "LTC", "EUR"); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("got %v, want ErrRateUnavailable", err)
+	}
+}
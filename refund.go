@@ -0,0 +1,39 @@
+package btcpay
+
+// RefundVariant defines how the refund amount is calculated.
+type RefundVariant string
+
+const (
+	RefundRateThen       RefundVariant = "RateThen"       // refund the crypto amount which has been paid
+	RefundCurrentRate    RefundVariant = "CurrentRate"    // refund the invoice amount, converted at the current rate
+	RefundFiat           RefundVariant = "Fiat"           // refund the invoice amount in the invoice currency
+	RefundCustom         RefundVariant = "Custom"         // refund InvoiceRefundRequest.CustomAmount in InvoiceRefundRequest.CustomCurrency
+	RefundOverpaidAmount RefundVariant = "OverpaidAmount" // refund the amount which has been paid in excess
+)
+
+// Mandatory fields are payment method and refund variant.
+type InvoiceRefundRequest struct {
+	Name               string        `json:"name,omitempty"`        // name of the pull payment, default: "Refund" followed by the invoice ID
+	Description        string        `json:"description,omitempty"` // description of the pull payment
+	PaymentMethod      string        `json:"paymentMethod"`         // payment method of the refund, example: "BTC"
+	RefundVariant      RefundVariant `json:"refundVariant"`
+	SubtractPercentage *Amount       `json:"subtractPercentage,omitempty"` // percentage subtracted from the refund amount, e.g. to cover fees, example: 5 (for 5%)
+	CustomAmount       *Amount       `json:"customAmount,omitempty"`       // RefundCustom only
+	CustomCurrency     string        `json:"customCurrency,omitempty"`     // RefundCustom only
+}
+
+// A PullPayment allows the recipient to claim payouts up to an amount. Refunds are implemented as pull payments.
+type PullPayment struct {
+	ID                string   `json:"id"`
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Currency          string   `json:"currency"`
+	Amount            Amount   `json:"amount"`           // example: "0.00036652"
+	Period            int64    `json:"period"`           // length of the payout period in seconds, zero if there is no period
+	BOLT11Expiration  string   `json:"BOLT11Expiration"` // example: "30" (days)
+	Archived          bool     `json:"archived"`
+	AutoApproveClaims bool     `json:"autoApproveClaims"`
+	ViewLink          string   `json:"viewLink"` // URL of the pull payment page where the recipient claims the refund, see also PullPaymentLink
+	StartsAt          UnixTime `json:"startsAt"`
+	ExpiresAt         UnixTime `json:"expiresAt"` // zero if it does not expire
+}
@@ -0,0 +1,112 @@
+package btcpay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultClient is used for API requests if ServerStore.Client is nil.
+var DefaultClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// MaxResponseSize limits the size of API response bodies which are read into memory.
+const MaxResponseSize = 10 * 1024 * 1024
+
+func (s *ServerStore) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return DefaultClient
+}
+
+// do performs an API request. It is the single code path for all Greenfield API calls.
+// If in is not nil, it is marshaled into the JSON request body.
+// If out is not nil, the JSON response body is unmarshaled into it.
+// Any status code other than 2xx results in an *APIError.
+func (s *ServerStore) do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+
+	var payload []byte
+	if in != nil {
+		var err error
+		payload, err = json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshaling request: %w", err)
+		}
+	}
+
+	resp, err := s.doRequest(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize+1))
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if len(body) > MaxResponseSize {
+		return fmt.Errorf("response body exceeds %d bytes", MaxResponseSize)
+	}
+
+	if out == nil || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return nil
+}
+
+// doRequest sends a request to the API, retrying it according to s.Retry. The caller must close the response body.
+func (s *ServerStore) doRequest(ctx context.Context, method string, path string, payload []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := s.send(ctx, method, path, payload)
+		if ctx.Err() != nil || !s.Retry.retry(method, attempt, resp, err) {
+			return resp, err
+		}
+		wait := s.Retry.backoff(attempt, resp)
+		if resp != nil {
+			discard(resp)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send sends a single request to the API. The caller must close the response body.
+func (s *ServerStore) send(ctx context.Context, method string, path string, payload []byte) (*http.Response, error) {
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		method,
+		fmt.Sprintf("%s/api/v1/%s", s.Host, path),
+		body,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("token %s", s.UserAPIKey))
+	req.Header.Add("Accept", "application/json")
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	return s.client().Do(req)
+}
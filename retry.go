@@ -0,0 +1,105 @@
+package btcpay
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines how idempotent API requests (GET and HEAD) are retried on network errors and on the status codes 429, 502, 503 and 504, e.g. while BTCPay Server restarts.
+// Other requests are never retried, because BTCPay Server might have processed them already.
+type RetryPolicy struct {
+	MaxAttempts int           // including the first attempt, values below 2 disable retries
+	MinBackoff  time.Duration // backoff before the first retry, doubled with each retry, default: 500 milliseconds
+	MaxBackoff  time.Duration // upper limit for the backoff and for the Retry-After response header, default: 30 seconds
+}
+
+// retry reports whether the request should be sent again after the given attempt.
+func (p *RetryPolicy) retry(method string, attempt int, resp *http.Response, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+	if err != nil {
+		return true // network error, context errors are checked by the caller
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the duration to wait after the given attempt. It honours the Retry-After header of resp, which can be nil.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+
+	var min = p.MinBackoff
+	if min <= 0 {
+		min = 500 * time.Millisecond
+	}
+	var max = p.MaxBackoff
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > max {
+				wait = max
+			}
+			return wait
+		}
+	}
+
+	// exponential backoff with jitter: a random duration between d/2 and d
+	var d = min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleep waits for the given duration or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// discard drains and closes a response body, so the connection can be reused.
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+	resp.Body.Close()
+}
@@ -0,0 +1,64 @@
+package btcpay
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+
+	var attempts int
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"version": "1.0.0"}`))
+	})
+	store.Retry = &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+	}
+
+	if _, err := store.GetServerStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("got %d attempts, want 3", attempts)
+	}
+
+	// POST requests are not retried
+	attempts = 0
+	if _, err := store.CreateInvoice(&InvoiceRequest{}); err == nil {
+		t.Error("got nil error")
+	}
+	if attempts != 1 {
+		t.Errorf("got %d attempts, want 1", attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	policy := &RetryPolicy{
+		MinBackoff: time.Second,
+		MaxBackoff: 5 * time.Second,
+	}
+
+	for attempt, want := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if attempt == 0 {
+			continue
+		}
+		got := policy.backoff(attempt, nil)
+		if got < want/2 || got > want {
+			t.Errorf("attempt %d: got backoff %v, want between %v and %v", attempt, got, want/2, want)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"120"}}}
+	if got := policy.backoff(1, resp); got != 5*time.Second {
+		t.Errorf("got backoff %v, want Retry-After capped to 5s", got)
+	}
+}
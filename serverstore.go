@@ -1,7 +1,7 @@
 package btcpay
 
 import (
-	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -15,20 +15,19 @@ import (
 	"time"
 )
 
-var (
-	ErrUnauthenticated = errors.New("unauthenticated")
-	ErrUnauthorized    = errors.New("unauthorized")
-	ErrBadRequest      = errors.New("bad request")
-	ErrNotFound        = errors.New("not found")
-)
-
 type ServerStore struct {
-	Host          string             `json:"uri"`        // without "/api" and without trailing slash, used for API access and user links
-	HostOnion     string             `json:"onion"`      // without "/api" and without trailing slash, used for user links only, can be empty
-	UserAPIKey    string             `json:"userAPIKey"` // to be created in the BTCPay Server user settings (not in the store settings)
-	ID            string             `json:"id"`
-	WebhookSecret string             `json:"webhookSecret"`
-	MaxRates      map[string]float64 `json:"maxRates"` // example: {"XMR": 1000, "BTC": 500000}
+	Host             string            `json:"uri"`        // without "/api" and without trailing slash, used for API access and user links
+	HostOnion        string            `json:"onion"`      // without "/api" and without trailing slash, used for user links only, can be empty
+	UserAPIKey       string            `json:"userAPIKey"` // to be created in the BTCPay Server user settings (not in the store settings)
+	ID               string            `json:"id"`
+	WebhookSecret    string            `json:"webhookSecret"`
+	MaxRates         map[string]Amount `json:"maxRates"`         // example: {"XMR": 1000, "BTC": 500000}
+	MinRates         map[string]Amount `json:"minRates"`         // example: {"XMR": 50, "BTC": 10000}
+	MaxRateDeviation Amount            `json:"maxRateDeviation"` // max relative deviation from RateSource, example: 0.05 for 5 %, zero disables the check
+	RateSource       RateSource        `json:"-"`                // reference rates for MaxRateDeviation, can be nil
+	Client           *http.Client      `json:"-"`                // used for API requests, can be nil (then DefaultClient is used), set it for custom TLS roots, proxies or transports
+	Retry            *RetryPolicy      `json:"-"`                // retry policy for idempotent API requests, nil disables retries
+	Validators       []EventValidator  `json:"-"`                // checks webhook events after signature verification, nil means DefaultValidators, use an empty slice to disable checks
 }
 
 // Load unmarshals a json config file into a ServerStore.
@@ -59,32 +58,46 @@ func Create(jsonPath string) error {
 	return fmt.Errorf("created empty config file: %s", jsonPath)
 }
 
-func (s *ServerStore) doRequest(method string, path string, body io.Reader) (*http.Response, error) {
+// ActivateInvoicePaymentMethod generates the payment destination of an invoice payment method, e.g. "BTC" or "XMR".
+// This is required if the invoice has been created with InvoiceCheckout.LazyPaymentMethods.
+// Call GetInvoicePaymentMethods afterwards in order to get the destination.
+func (s *ServerStore) ActivateInvoicePaymentMethod(invoiceID string, paymentMethod string) error {
+	return s.ActivateInvoicePaymentMethodContext(context.Background(), invoiceID, paymentMethod)
+}
 
-	req, err := http.NewRequest(
-		method,
-		fmt.Sprintf("%s/api/v1/%s", s.Host, path),
-		body,
-	)
-	if err != nil {
-		return nil, err
-	}
+func (s *ServerStore) ActivateInvoicePaymentMethodContext(ctx context.Context, invoiceID string, paymentMethod string) error {
+	return s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/invoices/%s/payment-methods/%s/activate", s.ID, invoiceID, paymentMethod), nil, nil)
+}
 
-	req.Header.Add("Authorization", fmt.Sprintf("token %s", s.UserAPIKey))
-	req.Header.Add("Content-Type", "application/json")
+// ArchiveInvoice archives an invoice. Archived invoices are hidden in the invoice list of your BTCPay server.
+func (s *ServerStore) ArchiveInvoice(id string) error {
+	return s.ArchiveInvoiceContext(context.Background(), id)
+}
+
+func (s *ServerStore) ArchiveInvoiceContext(ctx context.Context, id string) error {
+	return s.do(ctx, http.MethodDelete, fmt.Sprintf("stores/%s/invoices/%s", s.ID, id), nil, nil)
+}
 
-	return (&http.Client{
-		Timeout: 10 * time.Second,
-	}).Do(req)
+// ArchivePaymentRequest archives a payment request. Archived payment requests can't be paid any more.
+func (s *ServerStore) ArchivePaymentRequest(id string) error {
+	return s.ArchivePaymentRequestContext(context.Background(), id)
+}
+
+func (s *ServerStore) ArchivePaymentRequestContext(ctx context.Context, id string) error {
+	return s.do(ctx, http.MethodDelete, fmt.Sprintf("stores/%s/payment-requests/%s", s.ID, id), nil, nil)
 }
 
 // CheckInvoiceAuth checks authentication and authorization by performing bogus CreateInvoice and GetInvoice calls and checking the result.
-// It returns ErrUnauthenticated, ErrUnauthorized or nil.
+// It returns an error matching ErrUnauthenticated or ErrUnauthorized, another error or nil.
 func (s *ServerStore) CheckInvoiceAuth() error {
-	if _, err := s.CreateInvoice(nil); err != ErrBadRequest {
+	return s.CheckInvoiceAuthContext(context.Background())
+}
+
+func (s *ServerStore) CheckInvoiceAuthContext(ctx context.Context) error {
+	if _, err := s.CreateInvoiceContext(ctx, nil); !errors.Is(err, ErrBadRequest) {
 		return err
 	}
-	if _, err := s.GetInvoice("not-existing"); err != ErrNotFound {
+	if _, err := s.GetInvoiceContext(ctx, "not-existing"); !errors.Is(err, ErrNotFound) {
 		return err
 	}
 	return nil
@@ -94,234 +107,446 @@ func (s *ServerStore) CheckInvoiceAuth() error {
 // It is recommended to set InvoiceRequest.InvoiceMetadata.OrderID in order to
 // identify the order in both a webhook and in your bookkeeping.
 // Alternatively you can store the btcpay invoice ID in your order database.
+//
+// If MaxRates, MinRates or MaxRateDeviation is set, the rates of the new invoice are checked.
+// If a rate is out of bounds, the invoice is marked as invalid and an error wrapping ErrRateOutOfRange is returned.
+// If the invoice can't be marked, e.g. because an API request fails, the error is returned and the invoice stays new.
+// If the check itself can't be done, the invoice is returned together with the error. It stays new, but its rates have not been checked.
+// Use CreateInvoiceOnce for retrying, which checks the rates of a reused invoice again.
 func (s *ServerStore) CreateInvoice(req *InvoiceRequest) (*Invoice, error) {
+	return s.CreateInvoiceContext(context.Background(), req)
+}
 
-	payload, err := json.Marshal(req)
-	if err != nil {
+func (s *ServerStore) CreateInvoiceContext(ctx context.Context, req *InvoiceRequest) (*Invoice, error) {
+	var invoice = &Invoice{}
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/invoices", s.ID), req, invoice); err != nil {
 		return nil, err
 	}
+	return s.checkInvoiceRates(ctx, invoice)
+}
+
+// CreateInvoiceOnce returns an invoice with the same InvoiceMetadata.OrderID if there is one which has not expired and is not invalid.
+// Otherwise it creates an invoice like CreateInvoice. Use it if you retry the invoice creation of an order, e.g. after a timeout.
+// The rates of a reused new invoice are checked like in CreateInvoice. If they are out of bounds, the invoice is marked as invalid and a new one is created.
+// The OrderID must not be empty.
+func (s *ServerStore) CreateInvoiceOnce(req *InvoiceRequest) (*Invoice, error) {
+	return s.CreateInvoiceOnceContext(context.Background(), req)
+}
 
-	resp, err := s.doRequest(http.MethodPost, fmt.Sprintf("stores/%s/invoices", s.ID), bytes.NewBuffer(payload))
+func (s *ServerStore) CreateInvoiceOnceContext(ctx context.Context, req *InvoiceRequest) (*Invoice, error) {
+	if req == nil || req.InvoiceMetadata.OrderID == "" {
+		return nil, ErrOrderIDMissing
+	}
+	invoices, err := s.ListInvoicesContext(ctx, &InvoiceFilter{
+		OrderIDs: []string{req.InvoiceMetadata.OrderID},
+		Statuses: []InvoiceStatus{InvoiceNew, InvoiceProcessing, InvoiceSettled},
+	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("searching invoices: %w", err)
 	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// ok
-	case http.StatusUnauthorized: // 401, "Unauthorized" should be "Unauthenticated"
-		return nil, ErrUnauthenticated
-	case http.StatusForbidden:
-		return nil, ErrUnauthorized
-	case http.StatusBadRequest:
-		return nil, ErrBadRequest
-	case http.StatusNotFound:
-		return nil, ErrNotFound
-	default:
-		return nil, fmt.Errorf("response status: %d", resp.StatusCode)
+	if invoice := findReusableInvoice(invoices, req.InvoiceMetadata.OrderID, time.Now()); invoice != nil {
+		if invoice.Status != InvoiceNew {
+			return invoice, nil // already paid
+		}
+		checked, err := s.checkInvoiceRates(ctx, invoice)
+		if checked != nil || !errors.Is(err, ErrRateOutOfRange) {
+			return checked, err
+		}
+		// the invoice has been marked as invalid, create a new one
 	}
+	return s.CreateInvoiceContext(ctx, req)
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+// CreateInvoiceRefund creates a pull payment which refunds the given invoice.
+// Send the link returned by PullPaymentLink (or PullPayment.ViewLink) to the customer, who can claim the refund there.
+func (s *ServerStore) CreateInvoiceRefund(invoiceID string, req *InvoiceRefundRequest) (*PullPayment, error) {
+	return s.CreateInvoiceRefundContext(context.Background(), invoiceID, req)
+}
 
-	var invoice = &Invoice{}
-	return invoice, json.Unmarshal(body, invoice)
+func (s *ServerStore) CreateInvoiceRefundContext(ctx context.Context, invoiceID string, req *InvoiceRefundRequest) (*PullPayment, error) {
+	var pullPayment = &PullPayment{}
+	return pullPayment, s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/invoices/%s/refund", s.ID, invoiceID), req, pullPayment)
 }
 
 func (s *ServerStore) CreatePaymentRequest(req *PaymentRequestRequest) (*PaymentRequest, error) {
+	return s.CreatePaymentRequestContext(context.Background(), req)
+}
 
-	payload, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
+func (s *ServerStore) CreatePaymentRequestContext(ctx context.Context, req *PaymentRequestRequest) (*PaymentRequest, error) {
+	var paymentRequest = &PaymentRequest{}
+	return paymentRequest, s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/payment-requests", s.ID), req, paymentRequest)
+}
 
-	resp, err := s.doRequest(http.MethodPost, fmt.Sprintf("stores/%s/payment-requests", s.ID), bytes.NewBuffer(payload))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// ok
-	case http.StatusUnauthorized: // 401, "Unauthorized" should be "Unauthenticated"
-		return nil, ErrUnauthenticated
-	case http.StatusForbidden:
-		return nil, ErrUnauthorized
-	case http.StatusBadRequest:
-		return nil, ErrBadRequest
-	case http.StatusNotFound:
-		return nil, ErrNotFound
-	default:
-		return nil, fmt.Errorf("response status: %d", resp.StatusCode)
-	}
+// CreateWebhook registers a webhook. Store the returned Webhook.Secret in ServerStore.WebhookSecret, so ProcessWebhook can verify the events.
+func (s *ServerStore) CreateWebhook(req *WebhookRequest) (*Webhook, error) {
+	return s.CreateWebhookContext(context.Background(), req)
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+func (s *ServerStore) CreateWebhookContext(ctx context.Context, req *WebhookRequest) (*Webhook, error) {
+	var webhook = &Webhook{}
+	return webhook, s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/webhooks", s.ID), req, webhook)
+}
+
+// DefaultValidators returns the validators which are used if Validators is nil:
+// a store ID check and a rate check (MaxRates, MinRates, MaxRateDeviation), which applies to PaymentEventTypes only.
+func (s *ServerStore) DefaultValidators() []EventValidator {
+	return []EventValidator{
+		StoreIDValidator(s.ID),
+		ForEventTypes(s.rateValidator(), PaymentEventTypes...),
 	}
+}
 
-	var paymentRequest = &PaymentRequest{}
-	return paymentRequest, json.Unmarshal(body, paymentRequest)
+func (s *ServerStore) DeleteWebhook(id string) error {
+	return s.DeleteWebhookContext(context.Background(), id)
+}
+
+func (s *ServerStore) DeleteWebhookContext(ctx context.Context, id string) error {
+	return s.do(ctx, http.MethodDelete, fmt.Sprintf("stores/%s/webhooks/%s", s.ID, id), nil, nil)
 }
 
 func (s *ServerStore) GetInvoice(id string) (*Invoice, error) {
+	return s.GetInvoiceContext(context.Background(), id)
+}
+
+func (s *ServerStore) GetInvoiceContext(ctx context.Context, id string) (*Invoice, error) {
+	var invoice = &Invoice{}
+	return invoice, s.do(ctx, http.MethodGet, fmt.Sprintf("stores/%s/invoices/%s", s.ID, id), nil, invoice)
+}
+
+// GetInvoicePaymentMethods returns the payment methods of an invoice, including exchange rates and payments.
+// Only payments which count towards the invoice are returned, see GetInvoicePaymentMethodsIncludeUnaccounted.
+func (s *ServerStore) GetInvoicePaymentMethods(id string) ([]InvoicePaymentMethod, error) {
+	return s.GetInvoicePaymentMethodsContext(context.Background(), id)
+}
+
+func (s *ServerStore) GetInvoicePaymentMethodsContext(ctx context.Context, id string) ([]InvoicePaymentMethod, error) {
+	var methods = []InvoicePaymentMethod{}
+	return methods, s.do(ctx, http.MethodGet, fmt.Sprintf("stores/%s/invoices/%s/payment-methods", s.ID, id), nil, &methods)
+}
+
+// GetInvoicePaymentMethodsIncludeUnaccounted is like GetInvoicePaymentMethods, but returns payments which don't count towards the invoice (e.g. double spends) as well.
+func (s *ServerStore) GetInvoicePaymentMethodsIncludeUnaccounted(id string) ([]InvoicePaymentMethod, error) {
+	return s.GetInvoicePaymentMethodsIncludeUnaccountedContext(context.Background(), id)
+}
+
+func (s *ServerStore) GetInvoicePaymentMethodsIncludeUnaccountedContext(ctx context.Context, id string) ([]InvoicePaymentMethod, error) {
+	var methods = []InvoicePaymentMethod{}
+	return methods, s.do(ctx, http.MethodGet, fmt.Sprintf("stores/%s/invoices/%s/payment-methods?onlyAccountedPayments=false", s.ID, id), nil, &methods)
+}
+
+func (s *ServerStore) GetPaymentRequest(id string) (*PaymentRequest, error) {
+	return s.GetPaymentRequestContext(context.Background(), id)
+}
+
+func (s *ServerStore) GetPaymentRequestContext(ctx context.Context, id string) (*PaymentRequest, error) {
+	var paymentRequest = &PaymentRequest{}
+	return paymentRequest, s.do(ctx, http.MethodGet, fmt.Sprintf("stores/%s/payment-requests/%s", s.ID, id), nil, paymentRequest)
+}
+
+// GetServerStatus requires successful authentication, but no specific permissions.
+func (s *ServerStore) GetServerStatus() (*ServerStatus, error) {
+	return s.GetServerStatusContext(context.Background())
+}
+
+func (s *ServerStore) GetServerStatusContext(ctx context.Context) (*ServerStatus, error) {
+	var status = &ServerStatus{}
+	return status, s.do(ctx, http.MethodGet, "server/info", nil, status)
+}
+
+func (s *ServerStore) GetWebhook(id string) (*Webhook, error) {
+	return s.GetWebhookContext(context.Background(), id)
+}
 
-	resp, err := s.doRequest(http.MethodGet, fmt.Sprintf("stores/%s/invoices/%s", s.ID, id), nil)
+func (s *ServerStore) GetWebhookContext(ctx context.Context, id string) (*Webhook, error) {
+	var webhook = &Webhook{}
+	return webhook, s.do(ctx, http.MethodGet, fmt.Sprintf("stores/%s/webhooks/%s", s.ID, id), nil, webhook)
+}
+
+// GetWebhookDelivery returns the status of a webhook delivery.
+func (s *ServerStore) GetWebhookDelivery(webhookID string, deliveryID string) (*WebhookDelivery, error) {
+	return s.GetWebhookDeliveryContext(context.Background(), webhookID, deliveryID)
+}
+
+func (s *ServerStore) GetWebhookDeliveryContext(ctx context.Context, webhookID string, deliveryID string) (*WebhookDelivery, error) {
+	var delivery = &WebhookDelivery{}
+	return delivery, s.do(ctx, http.MethodGet, fmt.Sprintf("stores/%s/webhooks/%s/deliveries/%s", s.ID, webhookID, deliveryID), nil, delivery)
+}
+
+// GetWebhookDeliveryRequest fetches the original payload of a webhook delivery and processes it like ProcessWebhook (without signature verification, as the payload comes from the API).
+// The returned event has IsRedelivery set and OriginalDeliveryID populated, so it can be deduplicated against the original delivery.
+func (s *ServerStore) GetWebhookDeliveryRequest(webhookID string, deliveryID string) (Event, error) {
+	return s.GetWebhookDeliveryRequestContext(context.Background(), webhookID, deliveryID)
+}
+
+func (s *ServerStore) GetWebhookDeliveryRequestContext(ctx context.Context, webhookID string, deliveryID string) (Event, error) {
+	var body json.RawMessage
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("stores/%s/webhooks/%s/deliveries/%s/request", s.ID, webhookID, deliveryID), nil, &body); err != nil {
+		return nil, err
+	}
+	event, err := s.processEvent(ctx, body)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// ok
-	case http.StatusUnauthorized: // 401, "Unauthorized" should be "Unauthenticated"
-		return nil, ErrUnauthenticated
-	case http.StatusForbidden:
-		return nil, ErrUnauthorized
-	case http.StatusBadRequest:
-		return nil, ErrBadRequest
-	case http.StatusNotFound:
-		return nil, ErrNotFound
-	default:
-		return nil, fmt.Errorf("response status: %d", resp.StatusCode)
+	var envelope = event.Envelope()
+	if envelope.OriginalDeliveryID == "" {
+		envelope.OriginalDeliveryID = envelope.DeliveryID
 	}
+	envelope.IsRedelivery = true
+	return event, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+func (s *ServerStore) InvoiceCheckoutLink(id string) string {
+	return fmt.Sprintf("%s/i/%s", s.Host, id)
+}
+
+func (s *ServerStore) InvoiceCheckoutLinkPreferOnion(id string) string {
+	host := s.Host
+	if s.HostOnion != "" {
+		host = s.HostOnion
 	}
+	return fmt.Sprintf("%s/i/%s", host, id)
+}
 
-	var invoice = &Invoice{}
-	return invoice, json.Unmarshal(body, invoice)
+// IterateInvoices returns an iterator over all invoices matching filter, which can be nil.
+// It fetches InvoiceFilter.Take invoices (default: DefaultPageSize) per request, starting at InvoiceFilter.Skip.
+func (s *ServerStore) IterateInvoices(filter *InvoiceFilter) *InvoiceIterator {
+	return s.IterateInvoicesContext(context.Background(), filter)
 }
 
-func (s *ServerStore) GetInvoicePaymentMethods(id string) ([]InvoicePaymentMethod, error) {
+// IterateInvoicesContext is like IterateInvoices. The context is used for all requests of the iterator.
+func (s *ServerStore) IterateInvoicesContext(ctx context.Context, filter *InvoiceFilter) *InvoiceIterator {
+	var it = &InvoiceIterator{
+		ctx:   ctx,
+		store: s,
+		index: -1,
+	}
+	if filter != nil {
+		it.filter = *filter
+	}
+	if it.filter.Take <= 0 {
+		it.filter.Take = DefaultPageSize
+	}
+	return it
+}
 
-	resp, err := s.doRequest(http.MethodGet, fmt.Sprintf("stores/%s/invoices/%s/payment-methods", s.ID, id), nil)
-	if err != nil {
-		return nil, err
+// ListInvoices returns the invoices matching filter, which can be nil. BTCPay Server returns the newest invoices first.
+// Use InvoiceFilter.Skip and InvoiceFilter.Take for pagination, or IterateInvoices.
+func (s *ServerStore) ListInvoices(filter *InvoiceFilter) ([]Invoice, error) {
+	return s.ListInvoicesContext(context.Background(), filter)
+}
+
+func (s *ServerStore) ListInvoicesContext(ctx context.Context, filter *InvoiceFilter) ([]Invoice, error) {
+	var path = fmt.Sprintf("stores/%s/invoices", s.ID)
+	if query := filter.query().Encode(); query != "" {
+		path = fmt.Sprintf("%s?%s", path, query)
 	}
-	defer resp.Body.Close()
+	var invoices = []Invoice{}
+	return invoices, s.do(ctx, http.MethodGet, path, nil, &invoices)
+}
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// ok
-	case http.StatusForbidden: // 403
-		return nil, ErrUnauthorized
-	case http.StatusNotFound: // 404
-		return nil, ErrNotFound
-	default:
-		return nil, fmt.Errorf("response status: %d", resp.StatusCode)
+// ListPaymentRequests returns the payment requests of the store.
+func (s *ServerStore) ListPaymentRequests(includeArchived bool) ([]PaymentRequest, error) {
+	return s.ListPaymentRequestsContext(context.Background(), includeArchived)
+}
+
+func (s *ServerStore) ListPaymentRequestsContext(ctx context.Context, includeArchived bool) ([]PaymentRequest, error) {
+	var path = fmt.Sprintf("stores/%s/payment-requests", s.ID)
+	if includeArchived {
+		path = fmt.Sprintf("%s?includeArchived=true", path)
 	}
+	var paymentRequests = []PaymentRequest{}
+	return paymentRequests, s.do(ctx, http.MethodGet, path, nil, &paymentRequests)
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// ListWebhookDeliveries returns the latest deliveries of a webhook, newest first. If count is zero, the BTCPay Server default is used.
+func (s *ServerStore) ListWebhookDeliveries(webhookID string, count int) ([]WebhookDelivery, error) {
+	return s.ListWebhookDeliveriesContext(context.Background(), webhookID, count)
+}
+
+func (s *ServerStore) ListWebhookDeliveriesContext(ctx context.Context, webhookID string, count int) ([]WebhookDelivery, error) {
+	var path = fmt.Sprintf("stores/%s/webhooks/%s/deliveries", s.ID, webhookID)
+	if count > 0 {
+		path = fmt.Sprintf("%s?count=%d", path, count)
 	}
+	var deliveries = []WebhookDelivery{}
+	return deliveries, s.do(ctx, http.MethodGet, path, nil, &deliveries)
+}
 
-	var invoice = []InvoicePaymentMethod{}
-	return invoice, json.Unmarshal(body, &invoice)
+// ListWebhooks returns the webhooks of the store.
+func (s *ServerStore) ListWebhooks() ([]Webhook, error) {
+	return s.ListWebhooksContext(context.Background())
 }
 
-func (s *ServerStore) GetPaymentRequest(id string) (*PaymentRequest, error) {
+func (s *ServerStore) ListWebhooksContext(ctx context.Context) ([]Webhook, error) {
+	var webhooks = []Webhook{}
+	return webhooks, s.do(ctx, http.MethodGet, fmt.Sprintf("stores/%s/webhooks", s.ID), nil, &webhooks)
+}
 
-	resp, err := s.doRequest(http.MethodGet, fmt.Sprintf("stores/%s/payment-requests/%s", s.ID, id), nil)
-	if err != nil {
+// MarkInvoiceStatus manually marks an invoice as InvoiceSettled or InvoiceInvalid, e.g. if it has been underpaid.
+// The returned invoice has the additional status InvoiceAdditionalMarked, and webhook events about it have ManuallyMarked set.
+func (s *ServerStore) MarkInvoiceStatus(id string, status InvoiceStatus) (*Invoice, error) {
+	return s.MarkInvoiceStatusContext(context.Background(), id, status)
+}
+
+func (s *ServerStore) MarkInvoiceStatusContext(ctx context.Context, id string, status InvoiceStatus) (*Invoice, error) {
+	if err := checkMarkStatus(status); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// ok
-	case http.StatusUnauthorized: // 401, "Unauthorized" should be "Unauthenticated"
-		return nil, ErrUnauthenticated
-	case http.StatusForbidden:
-		return nil, ErrUnauthorized
-	case http.StatusBadRequest:
-		return nil, ErrBadRequest
-	case http.StatusNotFound:
-		return nil, ErrNotFound
-	default:
-		return nil, fmt.Errorf("response status: %d", resp.StatusCode)
+	var req = struct {
+		Status InvoiceStatus `json:"status"`
+	}{
+		Status: status,
 	}
+	var invoice = &Invoice{}
+	return invoice, s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/invoices/%s/status", s.ID, id), req, invoice)
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+// PayPaymentRequest creates an invoice for a payment request, which can be displayed using InvoiceCheckoutLink.
+// If amount is zero, the invoice amount is the amount due. Another amount is allowed only if PaymentRequestRequest.AllowCustomPaymentAmounts is set.
+// Otherwise BTCPay Server returns an error matching ErrValidation. The rates of the invoice are checked like in CreateInvoice.
+func (s *ServerStore) PayPaymentRequest(id string, amount Amount) (*Invoice, error) {
+	return s.PayPaymentRequestContext(context.Background(), id, amount)
+}
+
+func (s *ServerStore) PayPaymentRequestContext(ctx context.Context, id string, amount Amount) (*Invoice, error) {
+	var req = struct {
+		Amount *Amount `json:"amount,omitempty"`
+	}{}
+	if !amount.IsZero() {
+		req.Amount = &amount
+	}
+	var invoice = &Invoice{}
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/payment-requests/%s/pay", s.ID, id), req, invoice); err != nil {
 		return nil, err
 	}
+	return s.checkInvoiceRates(ctx, invoice)
+}
 
-	var paymentRequest = &PaymentRequest{}
-	return paymentRequest, json.Unmarshal(body, paymentRequest)
+func (s *ServerStore) PaymentRequestLink(id string) string {
+	return fmt.Sprintf("%s/payment-requests/%s", s.Host, id)
 }
 
-// GetServerStatus requires successful authentication, but no specific permissions.
-func (s *ServerStore) GetServerStatus() (*ServerStatus, error) {
+func (s *ServerStore) PaymentRequestLinkPreferOnion(id string) string {
+	host := s.Host
+	if s.HostOnion != "" {
+		host = s.HostOnion
+	}
+	return fmt.Sprintf("%s/payment-requests/%s", host, id)
+}
 
-	resp, err := s.doRequest(http.MethodGet, "server/info", nil)
+// ProcessWebhook verifies and validates a webhook request, see VerifyWebhook and ValidateEvent. The request context is used for subsequent API calls.
+// Errors wrap ErrInvalidSignature or ErrInvalidEvent if the request is invalid.
+func (s *ServerStore) ProcessWebhook(r *http.Request) (Event, error) {
+	event, err := s.VerifyWebhook(r)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// ok
-	case http.StatusUnauthorized: // 401, "Unauthorized" should be "Unauthenticated"
-		return nil, ErrUnauthenticated
-	case http.StatusForbidden:
-		return nil, ErrUnauthorized
-	case http.StatusBadRequest:
-		return nil, ErrBadRequest
-	case http.StatusNotFound:
-		return nil, ErrNotFound
-	default:
-		return nil, fmt.Errorf("response status: %d", resp.StatusCode)
+	if err := s.ValidateEvent(r.Context(), event); err != nil {
+		return nil, err
 	}
+	return event, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// processEvent decodes and validates a webhook event. It is used for delivery requests, which are not signed.
+func (s *ServerStore) processEvent(ctx context.Context, body []byte) (Event, error) {
+	event, err := DecodeEvent(body)
 	if err != nil {
 		return nil, err
 	}
-
-	var status = &ServerStatus{}
-	return status, json.Unmarshal(body, status)
+	if err := s.ValidateEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	return event, nil
 }
 
-func (s *ServerStore) InvoiceCheckoutLink(id string) string {
-	return fmt.Sprintf("%s/i/%s", s.Host, id)
+// PullPaymentLink returns the link to a pull payment page, where the recipient can claim a refund.
+func (s *ServerStore) PullPaymentLink(id string) string {
+	return fmt.Sprintf("%s/pull-payments/%s", s.Host, id)
 }
 
-func (s *ServerStore) InvoiceCheckoutLinkPreferOnion(id string) string {
+func (s *ServerStore) PullPaymentLinkPreferOnion(id string) string {
 	host := s.Host
 	if s.HostOnion != "" {
 		host = s.HostOnion
 	}
-	return fmt.Sprintf("%s/i/%s", host, id)
+	return fmt.Sprintf("%s/pull-payments/%s", host, id)
 }
 
-func (s *ServerStore) PaymentRequestLink(id string) string {
-	return fmt.Sprintf("%s/payment-requests/%s", s.Host, id)
+// ReceiptLink returns the link to the receipt page of a settled invoice.
+func (s *ServerStore) ReceiptLink(id string) string {
+	return fmt.Sprintf("%s/i/%s/receipt", s.Host, id)
 }
 
-func (s *ServerStore) PaymentRequestLinkPreferOnion(id string) string {
+func (s *ServerStore) ReceiptLinkPreferOnion(id string) string {
 	host := s.Host
 	if s.HostOnion != "" {
 		host = s.HostOnion
 	}
-	return fmt.Sprintf("%s/payment-requests/%s", host, id)
+	return fmt.Sprintf("%s/i/%s/receipt", host, id)
+}
+
+// RedeliverWebhook sends a webhook delivery again. It returns the ID of the new delivery.
+func (s *ServerStore) RedeliverWebhook(webhookID string, deliveryID string) (string, error) {
+	return s.RedeliverWebhookContext(context.Background(), webhookID, deliveryID)
 }
 
-func (s *ServerStore) ProcessWebhook(r *http.Request) (*InvoiceEvent, error) {
+func (s *ServerStore) RedeliverWebhookContext(ctx context.Context, webhookID string, deliveryID string) (string, error) {
+	var newDeliveryID string
+	return newDeliveryID, s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/webhooks/%s/deliveries/%s/redeliver", s.ID, webhookID, deliveryID), nil, &newDeliveryID)
+}
+
+// UnarchiveInvoice restores an archived invoice.
+func (s *ServerStore) UnarchiveInvoice(id string) (*Invoice, error) {
+	return s.UnarchiveInvoiceContext(context.Background(), id)
+}
+
+func (s *ServerStore) UnarchiveInvoiceContext(ctx context.Context, id string) (*Invoice, error) {
+	var invoice = &Invoice{}
+	return invoice, s.do(ctx, http.MethodPost, fmt.Sprintf("stores/%s/invoices/%s/unarchive", s.ID, id), nil, invoice)
+}
+
+// UpdatePaymentRequest replaces the fields of a payment request, e.g. in order to correct the amount.
+func (s *ServerStore) UpdatePaymentRequest(id string, req *PaymentRequestRequest) (*PaymentRequest, error) {
+	return s.UpdatePaymentRequestContext(context.Background(), id, req)
+}
+
+func (s *ServerStore) UpdatePaymentRequestContext(ctx context.Context, id string, req *PaymentRequestRequest) (*PaymentRequest, error) {
+	var paymentRequest = &PaymentRequest{}
+	return paymentRequest, s.do(ctx, http.MethodPut, fmt.Sprintf("stores/%s/payment-requests/%s", s.ID, id), req, paymentRequest)
+}
+
+// UpdateWebhook replaces the settings of a webhook. If WebhookRequest.Secret is empty, the secret is not changed.
+func (s *ServerStore) UpdateWebhook(id string, req *WebhookRequest) (*Webhook, error) {
+	return s.UpdateWebhookContext(context.Background(), id, req)
+}
+
+func (s *ServerStore) UpdateWebhookContext(ctx context.Context, id string, req *WebhookRequest) (*Webhook, error) {
+	var webhook = &Webhook{}
+	return webhook, s.do(ctx, http.MethodPut, fmt.Sprintf("stores/%s/webhooks/%s", s.ID, id), req, webhook)
+}
+
+// ValidateEvent runs the Validators (or DefaultValidators) on an event. It stops at the first error.
+// Use it after VerifyWebhook, e.g. in a background job.
+func (s *ServerStore) ValidateEvent(ctx context.Context, event Event) error {
+	var validators = s.Validators
+	if validators == nil {
+		validators = s.DefaultValidators()
+	}
+	for _, validator := range validators {
+		if err := validator.ValidateEvent(ctx, s, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyWebhook verifies the signature of a webhook request and decodes the event, see DecodeEvent. It does not call Validators and does no API requests.
+// Errors wrap ErrInvalidSignature or ErrInvalidEvent if the request is invalid.
+func (s *ServerStore) VerifyWebhook(r *http.Request) (Event, error) {
 
 	var messageMAC = []byte(strings.TrimPrefix(r.Header.Get("BTCPay-Sig"), "sha256="))
 	if len(messageMAC) == 0 {
-		return nil, errors.New("BTCPay-Sig header missing")
+		return nil, fmt.Errorf("%w: BTCPay-Sig header missing", ErrInvalidSignature)
 	}
 
 	body, err := io.ReadAll(r.Body)
@@ -333,29 +558,37 @@ func (s *ServerStore) ProcessWebhook(r *http.Request) (*InvoiceEvent, error) {
 	mac.Write(body)
 	var expectedMAC = []byte(hex.EncodeToString(mac.Sum(nil)))
 	if !hmac.Equal(messageMAC, expectedMAC) {
-		return nil, fmt.Errorf("HMAC mismatch, got %s, want %s", messageMAC, expectedMAC)
+		return nil, fmt.Errorf("%w: HMAC mismatch", ErrInvalidSignature)
 	}
 
-	var event = &InvoiceEvent{}
-	if err := json.Unmarshal(body, event); err != nil {
-		return nil, fmt.Errorf("unmarshaling body: %w", err)
-	}
+	return DecodeEvent(body)
+}
 
-	// mitigate BTCPayServer misconfigurations by checking the store ID
-	if event.StoreID != s.ID {
-		return nil, fmt.Errorf("invoice store ID %s does not match selected store ID %s", event.StoreID, s.ID)
+// checkInvoiceRates validates the rates of a new invoice like the default rate check of webhook events.
+// If a rate is out of bounds, the invoice is marked as invalid, so it can't be paid with a bad rate, and nil is returned.
+// The returned error wraps ErrRateOutOfRange only if the invoice has been marked as invalid.
+// If the check can't be done, e.g. because an API request fails, the unchecked invoice is returned together with the error.
+func (s *ServerStore) checkInvoiceRates(ctx context.Context, invoice *Invoice) (*Invoice, error) {
+	var validator = s.rateValidator()
+	if !validator.enabled() {
+		return invoice, nil
 	}
-
-	// mitigate invalid rates
-	paymentMethods, err := s.GetInvoicePaymentMethods(event.InvoiceID)
-	if err != nil {
-		return nil, fmt.Errorf("getting payment methods from invoice: %w", err)
+	err := validator.ValidateInvoice(ctx, s, invoice.ID, invoice.Currency)
+	if !errors.Is(err, ErrRateOutOfRange) {
+		return invoice, err
 	}
-	for cryptoCode, maxRate := range s.MaxRates {
-		if err := ValidateRate(paymentMethods, cryptoCode, maxRate); err != nil {
-			return nil, fmt.Errorf("validating rate: %w", err)
-		}
+	if _, markErr := s.MarkInvoiceStatusContext(ctx, invoice.ID, InvoiceInvalid); markErr != nil {
+		return nil, fmt.Errorf("marking invoice %s as invalid: %w (%v)", invoice.ID, markErr, err)
 	}
+	return nil, err
+}
 
-	return event, nil
+// rateValidator returns a RateValidator which is configured by the rate fields of the store.
+func (s *ServerStore) rateValidator() RateValidator {
+	return RateValidator{
+		MaxRates:     s.MaxRates,
+		MinRates:     s.MinRates,
+		Source:       s.RateSource,
+		MaxDeviation: s.MaxRateDeviation,
+	}
 }
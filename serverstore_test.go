@@ -0,0 +1,435 @@
+package btcpay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ StoreContext = &ServerStore{}
+
+// newTestStore returns a ServerStore whose API requests are served by handler.
+func newTestStore(t *testing.T, handler http.HandlerFunc) *ServerStore {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &ServerStore{
+		Host:       server.URL,
+		UserAPIKey: "test-api-key",
+		ID:         "test-store",
+		Client:     server.Client(),
+	}
+}
+
+func TestServerStoreClient(t *testing.T) {
+
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/server/info" {
+			t.Errorf("got path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "token test-api-key" {
+			t.Errorf("got authorization header %s", got)
+		}
+		w.Write([]byte(`{"version": "1.0.0", "fullySynched": true}`))
+	})
+
+	status, err := store.GetServerStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != "1.0.0" || !status.FullySynched {
+		t.Fail()
+	}
+}
+
+func TestServerStoreContext(t *testing.T) {
+
+	var started = make(chan struct{}, 1)
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done() // never respond
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := store.GetServerStatusContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-started:
+	default:
+		t.Error("request has not been sent")
+	}
+}
+
+func TestAPIError(t *testing.T) {
+
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/stores/test-store/invoices":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`[{"path": "amount", "message": "Amount should be greater than 0"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code": "invoice-not-found", "message": "The invoice was not found"}`))
+		}
+	})
+
+	_, err := store.CreateInvoice(&InvoiceRequest{Currency: "EUR"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want *APIError", err)
+	}
+	if !errors.Is(err, ErrValidation) || errors.Is(err, ErrNotFound) {
+		t.Errorf("error %v does not match ErrValidation", err)
+	}
+	if len(apiErr.FieldErrors) != 1 || apiErr.FieldErrors[0].Path != "amount" {
+		t.Errorf("got field errors %v", apiErr.FieldErrors)
+	}
+
+	_, err = store.GetInvoice("not-existing")
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want *APIError", err)
+	}
+	if !errors.Is(err, ErrNotFound) || apiErr.Code != "invoice-not-found" || apiErr.Message != "The invoice was not found" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestStatusMapping(t *testing.T) {
+
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthenticated},
+		{http.StatusForbidden, ErrUnauthorized},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusUnprocessableEntity, ErrValidation},
+	}
+
+	for _, test := range tests {
+		store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		})
+		if _, err := store.GetInvoicePaymentMethods("test-invoice"); !errors.Is(err, test.want) {
+			t.Errorf("status %d: got %v, want %v", test.status, err, test.want)
+		}
+		if _, err := store.GetServerStatus(); !errors.Is(err, test.want) {
+			t.Errorf("status %d: got %v, want %v", test.status, err, test.want)
+		}
+	}
+}
+
+func TestGetInvoicePaymentMethods(t *testing.T) {
+
+	var queries []string
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/stores/test-store/invoices/invoice-1/payment-methods" {
+			t.Errorf("got path %s", r.URL.Path)
+		}
+		queries = append(queries, r.URL.RawQuery)
+		w.Write([]byte(`[{"paymentMethod": "BTC", "cryptoCode": "BTC", "rate": "50000.5", "payments": [{"id": "payment-1", "value": "0.001", "status": "Invalid"}]}]`))
+	})
+
+	if _, err := store.GetInvoicePaymentMethods("invoice-1"); err != nil {
+		t.Fatal(err)
+	}
+	methods, err := store.GetInvoicePaymentMethodsIncludeUnaccounted("invoice-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 1 || methods[0].Rate.String() != "50000.5" || methods[0].Payments[0].Status != PaymentInvalid {
+		t.Errorf("got %+v", methods)
+	}
+	if strings.Join(queries, "|") != "|onlyAccountedPayments=false" {
+		t.Errorf("got queries %v", queries)
+	}
+}
+
+func TestCreateInvoiceOnce(t *testing.T) {
+
+	var created int
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if got := r.URL.Query().Get("orderId"); got != "order-1" {
+				t.Errorf("got orderId %s", got)
+			}
+			w.Write([]byte(`[{"id": "expired-invoice", "status": "Expired", "metadata": {"orderId": "order-1"}}, {"id": "expired-new-invoice", "status": "New", "expirationTime": 1610000000, "metadata": {"orderId": "order-1"}}, {"id": "new-invoice", "status": "New", "expirationTime": 4102444800, "metadata": {"orderId": "order-1"}}]`))
+		case http.MethodPost:
+			created++
+			w.Write([]byte(`{"id": "created-invoice", "status": "New"}`))
+		}
+	})
+
+	req := &InvoiceRequest{Currency: "EUR"}
+	if _, err := store.CreateInvoiceOnce(req); !errors.Is(err, ErrOrderIDMissing) {
+		t.Errorf("got %v, want ErrOrderIDMissing", err)
+	}
+
+	req.InvoiceMetadata.OrderID = "order-1"
+	invoice, err := store.CreateInvoiceOnce(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.ID != "new-invoice" || created != 0 {
+		t.Errorf("got invoice %s and %d created invoices, want the existing invoice", invoice.ID, created)
+	}
+}
+
+func TestCreateInvoiceRefund(t *testing.T) {
+
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/stores/test-store/invoices/invoice-1/refund" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if req["paymentMethod"] != "BTC" || req["refundVariant"] != "Custom" || req["subtractPercentage"] != "2.5" || req["customAmount"] != "12.50" || req["customCurrency"] != "EUR" {
+			t.Errorf("got request %v", req)
+		}
+		if _, ok := req["name"]; ok {
+			t.Errorf("empty name has been sent")
+		}
+		w.Write([]byte(`{"id": "pull-payment-1", "currency": "BTC", "amount": "0.00036652", "viewLink": "https://example.com/pull-payments/pull-payment-1", "startsAt": 1610000000, "expiresAt": null}`))
+	})
+
+	subtractPercentage := MustParseAmount("2.5")
+	customAmount := MustParseAmount("12.50")
+	pullPayment, err := store.CreateInvoiceRefund("invoice-1", &InvoiceRefundRequest{
+		PaymentMethod:      "BTC",
+		RefundVariant:      RefundCustom,
+		SubtractPercentage: &subtractPercentage,
+		CustomAmount:       &customAmount,
+		CustomCurrency:     "EUR",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pullPayment.ID != "pull-payment-1" || pullPayment.Amount.String() != "0.00036652" || pullPayment.StartsAt.Unix() != 1610000000 || !pullPayment.ExpiresAt.IsZero() {
+		t.Errorf("got %+v", pullPayment)
+	}
+	if link := store.PullPaymentLink(pullPayment.ID); link != store.Host+"/pull-payments/pull-payment-1" {
+		t.Errorf("got link %s", link)
+	}
+}
+
+func TestIterateInvoices(t *testing.T) {
+
+	var all = []string{"a", "b", "c", "d", "e"}
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if got := query.Get("status"); got != string(InvoiceSettled) {
+			t.Errorf("got status %s", got)
+		}
+		skip, _ := strconv.Atoi(query.Get("skip"))
+		take, _ := strconv.Atoi(query.Get("take"))
+		var page []Invoice
+		for i := skip; i < skip+take && i < len(all); i++ {
+			page = append(page, Invoice{ID: all[i]})
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+
+	it := store.IterateInvoicesContext(context.Background(), &InvoiceFilter{
+		Statuses: []InvoiceStatus{InvoiceSettled},
+		Take:     2,
+	})
+	var got []string
+	for it.Next() {
+		got = append(got, it.Invoice().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, "") != "abcde" {
+		t.Errorf("got %v, want %v", got, all)
+	}
+}
+
+func TestReceiptLink(t *testing.T) {
+
+	store := &ServerStore{Host: "https://example.com"}
+	if link := store.ReceiptLink("invoice-1"); link != "https://example.com/i/invoice-1/receipt" {
+		t.Errorf("got link %s", link)
+	}
+	if link := store.ReceiptLinkPreferOnion("invoice-1"); link != "https://example.com/i/invoice-1/receipt" {
+		t.Errorf("got onion link %s without onion host", link)
+	}
+	store.HostOnion = "http://example.onion"
+	if link := store.ReceiptLink("invoice-1"); link != "https://example.com/i/invoice-1/receipt" {
+		t.Errorf("got link %s", link)
+	}
+	if link := store.ReceiptLinkPreferOnion("invoice-1"); link != "http://example.onion/i/invoice-1/receipt" {
+		t.Errorf("got onion link %s", link)
+	}
+}
+
+func TestCreateWebhook(t *testing.T) {
+
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/stores/test-store/webhooks" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		var req WebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !req.Enabled || req.URL != "https://shop.example.com/webhook" || len(req.AuthorizedEvents.SpecificEvents) != 1 {
+			t.Errorf("got %+v", req)
+		}
+		req.Secret = "generated-secret"
+		json.NewEncoder(w).Encode(Webhook{WebhookRequest: req, ID: "webhook-1"})
+	})
+
+	webhook, err := store.CreateWebhook(&WebhookRequest{
+		Enabled:             true,
+		AutomaticRedelivery: true,
+		URL:                 "https://shop.example.com/webhook",
+		AuthorizedEvents: WebhookAuthorizedEvents{
+			SpecificEvents: []EventType{EventInvoiceSettled},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webhook.ID != "webhook-1" || webhook.Secret != "generated-secret" {
+		t.Errorf("got %+v", webhook)
+	}
+}
+
+func TestGetWebhookDeliveryRequest(t *testing.T) {
+
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/stores/test-store/webhooks/webhook-1/deliveries/delivery-1/request":
+			w.Write([]byte(`{"deliveryId": "delivery-1", "webhookId": "webhook-1", "type": "InvoiceSettled", "timestamp": 1610000000, "storeId": "test-store", "invoiceId": "invoice-1", "metadata": {"orderId": "order-1"}}`))
+		case "/api/v1/stores/test-store/invoices/invoice-1/payment-methods":
+			w.Write([]byte(`[]`))
+		default:
+			t.Errorf("got path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	delivered, err := store.GetWebhookDeliveryRequest("webhook-1", "delivery-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, ok := delivered.(*InvoiceSettledEvent)
+	if !ok {
+		t.Fatalf("got %T", delivered)
+	}
+	if event.InvoiceID != "invoice-1" || event.InvoiceMetadata.OrderID != "order-1" || event.Type != EventInvoiceSettled {
+		t.Errorf("got %+v", event)
+	}
+	if !event.IsRedelivery || event.OriginalDeliveryID != "delivery-1" {
+		t.Errorf("got IsRedelivery %t and OriginalDeliveryID %s", event.IsRedelivery, event.OriginalDeliveryID)
+	}
+}
+
+func TestCreateInvoiceRateGuard(t *testing.T) {
+
+	var (
+		rates         = map[string]string{} // key: invoice ID
+		methodsStatus = http.StatusOK
+		markStatus    = http.StatusOK
+		marked        []string
+		created       int
+	)
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/stores/test-store/invoices" && r.Method == http.MethodGet:
+			w.Write([]byte(`[{"id": "existing-invoice", "status": "New", "metadata": {"orderId": "order-1"}}]`))
+		case r.URL.Path == "/api/v1/stores/test-store/invoices" && r.Method == http.MethodPost:
+			created++
+			w.Write([]byte(`{"id": "created-invoice", "amount": "10", "currency": "EUR", "status": "New"}`))
+		case strings.HasSuffix(r.URL.Path, "/payment-methods"):
+			if methodsStatus != http.StatusOK {
+				w.WriteHeader(methodsStatus)
+				return
+			}
+			id := strings.Split(r.URL.Path, "/")[6]
+			fmt.Fprintf(w, `[{"paymentMethod": "BTC", "cryptoCode": "BTC", "rate": "%s"}]`, rates[id])
+		case strings.HasSuffix(r.URL.Path, "/status"):
+			if markStatus != http.StatusOK {
+				w.WriteHeader(markStatus)
+				return
+			}
+			var req struct {
+				Status InvoiceStatus `json:"status"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Status != InvoiceInvalid {
+				t.Errorf("got status %s", req.Status)
+			}
+			marked = append(marked, strings.Split(r.URL.Path, "/")[6])
+			w.Write([]byte(`{"status": "Invalid"}`))
+		default:
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	store.MinRates = map[string]Amount{"BTC": MustParseAmount("10000")}
+	req := &InvoiceRequest{Amount: MustParseAmount("10"), Currency: "EUR"}
+
+	// rate within bounds
+	rates["created-invoice"] = "50000"
+	if invoice, err := store.CreateInvoice(req); err != nil || invoice.ID != "created-invoice" || len(marked) != 0 {
+		t.Errorf("got %v, %v, marked %v", invoice, err, marked)
+	}
+
+	// rate out of bounds
+	rates["created-invoice"] = "5000"
+	if invoice, err := store.CreateInvoice(req); invoice != nil || !errors.Is(err, ErrRateOutOfRange) || strings.Join(marked, ",") != "created-invoice" {
+		t.Errorf("got %v, %v, marked %v, want nil, ErrRateOutOfRange and created-invoice marked", invoice, err, marked)
+	}
+	marked = nil
+
+	// missing rate is not checked
+	rates["created-invoice"] = "0"
+	if invoice, err := store.CreateInvoice(req); err != nil || invoice.ID != "created-invoice" || len(marked) != 0 {
+		t.Errorf("got %v, %v, marked %v", invoice, err, marked)
+	}
+
+	// check fails, the unmarked invoice is returned with the error
+	methodsStatus = http.StatusServiceUnavailable
+	if invoice, err := store.CreateInvoice(req); invoice == nil || invoice.ID != "created-invoice" || err == nil || errors.Is(err, ErrRateOutOfRange) || len(marked) != 0 {
+		t.Errorf("got %v, %v, marked %v, want created-invoice unmarked and an error", invoice, err, marked)
+	}
+	methodsStatus = http.StatusOK
+	rates["created-invoice"] = "5000"
+
+	// marking fails
+	markStatus = http.StatusForbidden
+	if invoice, err := store.CreateInvoice(req); invoice != nil || !errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrRateOutOfRange) {
+		t.Errorf("got %v, %v, want nil and ErrUnauthorized", invoice, err)
+	}
+
+	// a reused invoice is checked again and not replaced if it can't be marked
+	req.OrderID = "order-1"
+	rates["existing-invoice"] = "5000"
+	rates["created-invoice"] = "50000"
+	created = 0
+	if invoice, err := store.CreateInvoiceOnce(req); invoice != nil || err == nil || created != 0 {
+		t.Errorf("got %v, %v and %d created invoices, want nil and no created invoice", invoice, err, created)
+	}
+	markStatus = http.StatusOK
+
+	// a reused invoice with a bad rate is replaced
+	if invoice, err := store.CreateInvoiceOnce(req); err != nil || invoice.ID != "created-invoice" || created != 1 || strings.Join(marked, ",") != "existing-invoice" {
+		t.Errorf("got %v, %v, %d created invoices, marked %v", invoice, err, created, marked)
+	}
+}
@@ -1,21 +1,57 @@
 package btcpay
 
 import (
+	"context"
 	"net/http"
 )
 
 type Store interface {
+	ActivateInvoicePaymentMethod(invoiceID string, paymentMethod string) error
+	ArchiveInvoice(id string) error
+	ArchivePaymentRequest(id string) error
 	CheckInvoiceAuth() error
 	CreateInvoice(req *InvoiceRequest) (*Invoice, error)
+	CreateInvoiceOnce(req *InvoiceRequest) (*Invoice, error)
 	CreatePaymentRequest(req *PaymentRequestRequest) (*PaymentRequest, error)
 	GetInvoice(id string) (*Invoice, error)
+	GetInvoicePaymentMethods(id string) ([]InvoicePaymentMethod, error)
+	GetInvoicePaymentMethodsIncludeUnaccounted(id string) ([]InvoicePaymentMethod, error)
 	GetPaymentRequest(id string) (*PaymentRequest, error)
 	GetServerStatus() (*ServerStatus, error)
 	InvoiceCheckoutLink(id string) string
 	InvoiceCheckoutLinkPreferOnion(id string) string
+	ListPaymentRequests(includeArchived bool) ([]PaymentRequest, error)
+	MarkInvoiceStatus(id string, status InvoiceStatus) (*Invoice, error)
+	PayPaymentRequest(id string, amount Amount) (*Invoice, error)
 	PaymentRequestLink(id string) string
 	PaymentRequestLinkPreferOnion(id string) string
-	ProcessWebhook(req *http.Request) (*InvoiceEvent, error)
+	ProcessWebhook(req *http.Request) (Event, error)
+	ReceiptLink(id string) string
+	ReceiptLinkPreferOnion(id string) string
+	UnarchiveInvoice(id string) (*Invoice, error)
+	UpdatePaymentRequest(id string, req *PaymentRequestRequest) (*PaymentRequest, error)
+}
+
+// StoreContext extends Store by methods which accept a context. The context is passed to the underlying API requests, so they can be canceled and deadlines propagate.
+type StoreContext interface {
+	Store
+	ActivateInvoicePaymentMethodContext(ctx context.Context, invoiceID string, paymentMethod string) error
+	ArchiveInvoiceContext(ctx context.Context, id string) error
+	ArchivePaymentRequestContext(ctx context.Context, id string) error
+	CheckInvoiceAuthContext(ctx context.Context) error
+	CreateInvoiceContext(ctx context.Context, req *InvoiceRequest) (*Invoice, error)
+	CreateInvoiceOnceContext(ctx context.Context, req *InvoiceRequest) (*Invoice, error)
+	CreatePaymentRequestContext(ctx context.Context, req *PaymentRequestRequest) (*PaymentRequest, error)
+	GetInvoiceContext(ctx context.Context, id string) (*Invoice, error)
+	GetInvoicePaymentMethodsContext(ctx context.Context, id string) ([]InvoicePaymentMethod, error)
+	GetInvoicePaymentMethodsIncludeUnaccountedContext(ctx context.Context, id string) ([]InvoicePaymentMethod, error)
+	GetPaymentRequestContext(ctx context.Context, id string) (*PaymentRequest, error)
+	GetServerStatusContext(ctx context.Context) (*ServerStatus, error)
+	ListPaymentRequestsContext(ctx context.Context, includeArchived bool) ([]PaymentRequest, error)
+	MarkInvoiceStatusContext(ctx context.Context, id string, status InvoiceStatus) (*Invoice, error)
+	PayPaymentRequestContext(ctx context.Context, id string, amount Amount) (*Invoice, error)
+	UnarchiveInvoiceContext(ctx context.Context, id string) (*Invoice, error)
+	UpdatePaymentRequestContext(ctx context.Context, id string, req *PaymentRequestRequest) (*PaymentRequest, error)
 }
 
 type ServerStatus struct {
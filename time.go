@@ -0,0 +1,91 @@
+package btcpay
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnixTime is a time.Time which is represented as Unix seconds in JSON, like most Greenfield API timestamps.
+// The zero value is marshaled into 0. Both 0 and null are unmarshaled into the zero value.
+type UnixTime struct {
+	time.Time
+}
+
+// NewUnixTime returns t as UnixTime.
+func NewUnixTime(t time.Time) UnixTime {
+	return UnixTime{t}
+}
+
+func (t UnixTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("0"), nil
+	}
+	return []byte(strconv.FormatInt(t.Unix(), 10)), nil
+}
+
+func (t *UnixTime) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*t = UnixTime{}
+		return nil
+	}
+	var seconds int64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return err
+	}
+	if seconds == 0 {
+		*t = UnixTime{}
+	} else {
+		*t = UnixTime{time.Unix(seconds, 0)}
+	}
+	return nil
+}
+
+// RFC3339Time is a time.Time which is represented as an RFC3339 string with second precision in JSON, like the payment request dates.
+// Timestamps without a time zone offset are unmarshaled as UTC. The zero value is marshaled into null. Both null and "" are unmarshaled into the zero value.
+type RFC3339Time struct {
+	time.Time
+}
+
+// NewRFC3339Time returns t as RFC3339Time.
+func NewRFC3339Time(t time.Time) RFC3339Time {
+	return RFC3339Time{t}
+}
+
+func (t RFC3339Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Format(time.RFC3339))
+}
+
+func (t *RFC3339Time) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*t = RFC3339Time{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		*t = RFC3339Time{}
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		// without time zone offset
+		var withoutOffset error
+		parsed, withoutOffset = time.ParseInLocation("2006-01-02T15:04:05.999999999", s, time.UTC)
+		if withoutOffset != nil {
+			return err
+		}
+	}
+	*t = RFC3339Time{parsed}
+	return nil
+}
@@ -0,0 +1,72 @@
+package btcpay
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnixTime(t *testing.T) {
+
+	var invoice Invoice
+	if err := json.Unmarshal([]byte(`{"createdTime": 1610000000, "expirationTime": 1610000900, "monitoringExpiration": null}`), &invoice); err != nil {
+		t.Fatal(err)
+	}
+	if !invoice.CreatedTime.Equal(time.Unix(1610000000, 0)) || !invoice.MonitoringExpiration.IsZero() {
+		t.Errorf("got %v and %v", invoice.CreatedTime, invoice.MonitoringExpiration)
+	}
+
+	now := time.Unix(1610000600, 0)
+	if invoice.IsExpired(now) {
+		t.Error("invoice should not be expired")
+	}
+	if got := invoice.TimeLeft(now); got != 5*time.Minute {
+		t.Errorf("got %v time left, want 5m", got)
+	}
+	if !invoice.IsExpired(now.Add(time.Hour)) || invoice.TimeLeft(now.Add(time.Hour)) != 0 {
+		t.Error("invoice should be expired")
+	}
+
+	data, err := json.Marshal(struct {
+		A UnixTime `json:"a"`
+		B UnixTime `json:"b"`
+	}{A: invoice.CreatedTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":1610000000,"b":0}` {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestRFC3339Time(t *testing.T) {
+
+	var pr PaymentRequest
+	if err := json.Unmarshal([]byte(`{"created": "2021-01-07T06:13:20.123", "expiryDate": "2021-01-14T06:13:20+01:00"}`), &pr); err != nil {
+		t.Fatal(err)
+	}
+	if !pr.Created.Equal(time.Date(2021, 1, 7, 6, 13, 20, 123000000, time.UTC)) {
+		t.Errorf("got created %v", pr.Created)
+	}
+	if !pr.ExpiryDate.Equal(time.Date(2021, 1, 14, 5, 13, 20, 0, time.UTC)) {
+		t.Errorf("got expiry date %v", pr.ExpiryDate)
+	}
+	if !pr.IsExpired(time.Date(2021, 1, 14, 5, 13, 20, 0, time.UTC)) || pr.IsExpired(time.Date(2021, 1, 14, 5, 13, 19, 0, time.UTC)) {
+		t.Error("wrong expiry")
+	}
+
+	var req PaymentRequestRequest
+	req.SetExpiryDays(7)
+	data, err := json.Marshal(req.ExpiryDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse(`"`+time.RFC3339+`"`, string(data)); err != nil || strings.Contains(string(data), ".") {
+		t.Errorf("got %s, want second precision RFC3339", data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"created": ""}`), &pr); err != nil || !pr.Created.IsZero() {
+		t.Errorf("got %v, %v", pr.Created, err)
+	}
+}
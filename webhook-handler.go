@@ -0,0 +1,238 @@
+package btcpay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxWebhookSize is the default maximum size of a webhook request body.
+const DefaultMaxWebhookSize = 1024 * 1024
+
+// A WebhookHandler is an http.Handler which processes webhook requests with Store.ProcessWebhook and calls the callback which has been registered for the event type.
+//
+// It responds with status 200 if the callback succeeded or if no callback has been registered for the event type,
+// 401 if the signature is invalid, 400 if the event is invalid, 413 if the body exceeds MaxBodySize,
+// and 500 if processing failed or the callback returned an error or panicked.
+// BTCPay Server redelivers the event on failure if automatic redelivery is enabled for the webhook.
+//
+// Register the callbacks before the handler is mounted. The callbacks receive the request context.
+// If Deliveries is set, duplicate deliveries are acknowledged without calling the callback again.
+type WebhookHandler struct {
+	Store       Store
+	Deliveries  DeliveryStore                    // can be nil, then deliveries are not deduplicated
+	MaxBodySize int64                            // zero means DefaultMaxWebhookSize
+	ErrorLog    func(r *http.Request, err error) // called if the response status is not 200, can be nil
+	callbacks   map[EventType]func(context.Context, Event) error
+	fallback    func(context.Context, Event) error
+}
+
+func NewWebhookHandler(store Store) *WebhookHandler {
+	return &WebhookHandler{
+		Store:     store,
+		callbacks: make(map[EventType]func(context.Context, Event) error),
+	}
+}
+
+// Handle registers a callback for an event type, replacing any previous callback for it.
+func (h *WebhookHandler) Handle(eventType EventType, f func(ctx context.Context, event Event) error) {
+	if h.callbacks == nil {
+		h.callbacks = make(map[EventType]func(context.Context, Event) error)
+	}
+	h.callbacks[eventType] = f
+}
+
+// HandleDefault registers a callback for all events without a callback, including events of unknown type (see GenericEvent).
+func (h *WebhookHandler) HandleDefault(f func(ctx context.Context, event Event) error) {
+	h.fallback = f
+}
+
+func (h *WebhookHandler) OnInvoiceCreated(f func(ctx context.Context, event *InvoiceCreatedEvent) error) {
+	h.Handle(EventInvoiceCreated, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*InvoiceCreatedEvent))
+	})
+}
+
+func (h *WebhookHandler) OnInvoiceExpired(f func(ctx context.Context, event *InvoiceExpiredEvent) error) {
+	h.Handle(EventInvoiceExpired, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*InvoiceExpiredEvent))
+	})
+}
+
+func (h *WebhookHandler) OnInvoiceInvalid(f func(ctx context.Context, event *InvoiceInvalidEvent) error) {
+	h.Handle(EventInvoiceInvalid, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*InvoiceInvalidEvent))
+	})
+}
+
+func (h *WebhookHandler) OnInvoicePaymentMethodActivated(f func(ctx context.Context, event *InvoicePaymentMethodActivatedEvent) error) {
+	h.Handle(EventInvoicePaymentMethodActivated, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*InvoicePaymentMethodActivatedEvent))
+	})
+}
+
+func (h *WebhookHandler) OnInvoicePaymentSettled(f func(ctx context.Context, event *InvoicePaymentSettledEvent) error) {
+	h.Handle(EventInvoicePaymentSettled, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*InvoicePaymentSettledEvent))
+	})
+}
+
+func (h *WebhookHandler) OnInvoiceProcessing(f func(ctx context.Context, event *InvoiceProcessingEvent) error) {
+	h.Handle(EventInvoiceProcessing, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*InvoiceProcessingEvent))
+	})
+}
+
+func (h *WebhookHandler) OnInvoiceReceivedPayment(f func(ctx context.Context, event *InvoiceReceivedPaymentEvent) error) {
+	h.Handle(EventInvoiceReceivedPayment, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*InvoiceReceivedPaymentEvent))
+	})
+}
+
+func (h *WebhookHandler) OnInvoiceSettled(f func(ctx context.Context, event *InvoiceSettledEvent) error) {
+	h.Handle(EventInvoiceSettled, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*InvoiceSettledEvent))
+	})
+}
+
+func (h *WebhookHandler) OnPaymentRequestArchived(f func(ctx context.Context, event *PaymentRequestArchivedEvent) error) {
+	h.Handle(EventPaymentRequestArchived, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*PaymentRequestArchivedEvent))
+	})
+}
+
+func (h *WebhookHandler) OnPaymentRequestStatusChanged(f func(ctx context.Context, event *PaymentRequestStatusChangedEvent) error) {
+	h.Handle(EventPaymentRequestStatusChanged, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*PaymentRequestStatusChangedEvent))
+	})
+}
+
+func (h *WebhookHandler) OnPaymentRequestUpdated(f func(ctx context.Context, event *PaymentRequestUpdatedEvent) error) {
+	h.Handle(EventPaymentRequestUpdated, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*PaymentRequestUpdatedEvent))
+	})
+}
+
+func (h *WebhookHandler) OnPayoutApproved(f func(ctx context.Context, event *PayoutApprovedEvent) error) {
+	h.Handle(EventPayoutApproved, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*PayoutApprovedEvent))
+	})
+}
+
+func (h *WebhookHandler) OnPayoutCreated(f func(ctx context.Context, event *PayoutCreatedEvent) error) {
+	h.Handle(EventPayoutCreated, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*PayoutCreatedEvent))
+	})
+}
+
+func (h *WebhookHandler) OnPayoutUpdated(f func(ctx context.Context, event *PayoutUpdatedEvent) error) {
+	h.Handle(EventPayoutUpdated, func(ctx context.Context, event Event) error {
+		return f(ctx, event.(*PayoutUpdatedEvent))
+	})
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	defer func() {
+		if v := recover(); v != nil {
+			h.fail(w, r, http.StatusInternalServerError, fmt.Errorf("panic: %v", v))
+		}
+	}()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.fail(w, r, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	var maxBodySize = h.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxWebhookSize
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, fmt.Errorf("reading body: %w", err))
+		return
+	}
+	if int64(len(body)) > maxBodySize {
+		h.fail(w, r, http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", maxBodySize))
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	event, err := h.Store.ProcessWebhook(r)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrInvalidSignature):
+		h.fail(w, r, http.StatusUnauthorized, err)
+		return
+	case errors.Is(err, ErrInvalidEvent):
+		h.fail(w, r, http.StatusBadRequest, err)
+		return
+	default:
+		h.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.HandleEvent(r.Context(), event); err != nil {
+		h.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleEvent calls the callback which has been registered for the event type. It is called by ServeHTTP.
+// It can be used to process events from GetWebhookDeliveryRequest, e.g. in a recovery job.
+//
+// If Deliveries is set, the delivery is claimed before and released if the callback fails or panics, so a redelivery is processed again.
+// Duplicate deliveries are skipped and nil is returned.
+func (h *WebhookHandler) HandleEvent(ctx context.Context, event Event) (err error) {
+
+	var envelope = event.Envelope()
+	var callback = h.callbacks[envelope.Type]
+	if callback == nil {
+		callback = h.fallback
+	}
+	if callback == nil {
+		return nil
+	}
+
+	if key := DeliveryKey(event); h.Deliveries != nil && key != "" {
+		claimed, claimErr := h.Deliveries.Claim(key)
+		if claimErr != nil {
+			return fmt.Errorf("claiming delivery %s: %w", key, claimErr)
+		}
+		if !claimed {
+			return nil
+		}
+		defer func() {
+			if err != nil {
+				if releaseErr := h.Deliveries.Release(key); releaseErr != nil {
+					err = fmt.Errorf("%w (releasing delivery %s: %v)", err, key, releaseErr)
+				}
+			}
+		}()
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("handling %s event %s: panic: %v", envelope.Type, envelope.DeliveryID, v)
+		}
+	}()
+
+	if err := callback(ctx, event); err != nil {
+		return fmt.Errorf("handling %s event %s: %w", envelope.Type, envelope.DeliveryID, err)
+	}
+	return nil
+}
+
+func (h *WebhookHandler) fail(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if h.ErrorLog != nil {
+		h.ErrorLog(r, err)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
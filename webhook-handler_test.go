@@ -0,0 +1,111 @@
+package btcpay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newWebhookRequest returns a webhook request with the given body, signed with secret.
+func newWebhookRequest(secret string, body string) *http.Request {
+	var mac = hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	r.Header.Set("BTCPay-Sig", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestWebhookHandler(t *testing.T) {
+
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+	store.WebhookSecret = "test-secret"
+
+	var settled []string
+	handler := NewWebhookHandler(store)
+	handler.MaxBodySize = 1000
+	handler.OnInvoiceSettled(func(ctx context.Context, event *InvoiceSettledEvent) error {
+		switch event.InvoiceID {
+		case "invoice-error":
+			return errors.New("database unavailable")
+		case "invoice-panic":
+			panic("oops")
+		}
+		settled = append(settled, event.InvoiceID)
+		return nil
+	})
+
+	var tests = []struct {
+		request *http.Request
+		status  int
+	}{
+		{newWebhookRequest("test-secret", `{"type": "InvoiceSettled", "storeId": "test-store", "invoiceId": "invoice-1"}`), http.StatusOK},
+		{newWebhookRequest("test-secret", `{"type": "InvoiceCreated", "storeId": "test-store", "invoiceId": "invoice-2"}`), http.StatusOK},
+		{newWebhookRequest("test-secret", `{"type": "SomethingNew", "storeId": "test-store"}`), http.StatusOK},
+		{newWebhookRequest("wrong-secret", `{"type": "InvoiceSettled", "storeId": "test-store", "invoiceId": "invoice-3"}`), http.StatusUnauthorized},
+		{newWebhookRequest("test-secret", `{"type": "InvoiceSettled", "storeId": "other-store", "invoiceId": "invoice-4"}`), http.StatusBadRequest},
+		{newWebhookRequest("test-secret", `{"type": "InvoiceSettled", "storeId": "test-store", "invoiceId": "invoice-error"}`), http.StatusInternalServerError},
+		{newWebhookRequest("test-secret", `{"type": "InvoiceSettled", "storeId": "test-store", "invoiceId": "invoice-panic"}`), http.StatusInternalServerError},
+		{newWebhookRequest("test-secret", `{"type": "InvoiceSettled", "storeId": "test-store", "invoiceId": "`+strings.Repeat("x", 1000)+`"}`), http.StatusRequestEntityTooLarge},
+		{httptest.NewRequest(http.MethodGet, "/webhook", nil), http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, test.request)
+		if recorder.Code != test.status {
+			t.Errorf("got status %d, want %d", recorder.Code, test.status)
+		}
+	}
+
+	if len(settled) != 1 || settled[0] != "invoice-1" {
+		t.Errorf("got settled invoices %v", settled)
+	}
+}
+
+func TestWebhookHandlerDeliveries(t *testing.T) {
+
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+	store.WebhookSecret = "test-secret"
+
+	var calls int
+	var fail = true
+	handler := NewWebhookHandler(store)
+	handler.Deliveries = NewMemoryDeliveryStore()
+	handler.OnInvoiceSettled(func(ctx context.Context, event *InvoiceSettledEvent) error {
+		calls++
+		if fail {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+
+	var tests = []struct {
+		body   string
+		fail   bool
+		status int
+		calls  int
+	}{
+		{`{"deliveryId": "delivery-1", "originalDeliveryId": "delivery-1", "type": "InvoiceSettled", "storeId": "test-store", "invoiceId": "invoice-1"}`, true, http.StatusInternalServerError, 1},
+		{`{"deliveryId": "delivery-2", "originalDeliveryId": "delivery-1", "isRedelivery": true, "type": "InvoiceSettled", "storeId": "test-store", "invoiceId": "invoice-1"}`, false, http.StatusOK, 2},
+		{`{"deliveryId": "delivery-3", "originalDeliveryId": "delivery-1", "isRedelivery": true, "type": "InvoiceSettled", "storeId": "test-store", "invoiceId": "invoice-1"}`, false, http.StatusOK, 2},
+	}
+
+	for _, test := range tests {
+		fail = test.fail
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newWebhookRequest("test-secret", test.body))
+		if recorder.Code != test.status || calls != test.calls {
+			t.Errorf("got status %d and %d calls, want %d and %d", recorder.Code, calls, test.status, test.calls)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package btcpay
+
+// A Webhook sends events to a URL. The secret is returned by CreateWebhook only.
+type Webhook struct {
+	WebhookRequest
+	ID string `json:"id"`
+}
+
+// Mandatory field is URL. Note that Enabled defaults to false.
+type WebhookRequest struct {
+	Enabled             bool                    `json:"enabled"`
+	AutomaticRedelivery bool                    `json:"automaticRedelivery"` // redeliver failed events, see WebhookHandler
+	URL                 string                  `json:"url"`
+	AuthorizedEvents    WebhookAuthorizedEvents `json:"authorizedEvents"`
+	Secret              string                  `json:"secret,omitempty"` // used for signing the events, see ServerStore.WebhookSecret. If empty, BTCPay Server generates one on creation and keeps the old one on update.
+}
+
+// WebhookAuthorizedEvents selects the events which are sent by a webhook.
+type WebhookAuthorizedEvents struct {
+	Everything     bool        `json:"everything"`
+	SpecificEvents []EventType `json:"specificEvents,omitempty"` // used if Everything is false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryFailed      WebhookDeliveryStatus = "Failed"      // no HTTP response, e.g. connection refused
+	DeliveryHTTPError   WebhookDeliveryStatus = "HttpError"   // HTTP response with an error status code
+	DeliveryHTTPSuccess WebhookDeliveryStatus = "HttpSuccess" // HTTP response with a success status code
+)
+
+// A WebhookDelivery is an attempt to send an event to the webhook URL.
+type WebhookDelivery struct {
+	ID           string                `json:"id"`
+	Timestamp    UnixTime              `json:"timestamp"`
+	HTTPCode     int                   `json:"httpCode"`     // zero if there was no HTTP response
+	ErrorMessage string                `json:"errorMessage"` // empty on success
+	Status       WebhookDeliveryStatus `json:"status"`
+}